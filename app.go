@@ -19,6 +19,7 @@ type App struct {
 	config    *support.Config
 	dbManager *record.Engine
 	i18n      *support.I18n
+	lifecycle *support.Lifecycle
 	logger    *support.Logger
 	mailer    *mailer.Engine
 	server    *pack.Server
@@ -37,11 +38,12 @@ func NewApp(assetFS http.FileSystem, appRoot string, viewFuncs map[string]interf
 	asset := support.NewAsset(assetFS, appRoot)
 	config := support.NewConfig(asset, logger)
 	i18n := support.NewI18n(asset, config, logger)
+	lifecycle := support.NewLifecycle(config, logger)
 	dbManager := record.NewEngine(logger, i18n)
 	ml := mailer.NewEngine(asset, config, i18n, logger, viewFuncs)
-	server := pack.NewAppServer(asset, config, i18n, ml, logger, viewFuncs)
+	server := pack.NewAppServer(asset, config, i18n, ml, lifecycle, logger, viewFuncs)
 	worker := worker.NewEngine(asset, config, dbManager, logger)
-	cmd := cmd.NewAppCommand(asset, config, dbManager, logger, server, worker)
+	cmd := cmd.NewAppCommand(asset, config, dbManager, lifecycle, logger, ml, server, worker)
 
 	return &App{
 		asset,
@@ -49,6 +51,7 @@ func NewApp(assetFS http.FileSystem, appRoot string, viewFuncs map[string]interf
 		config,
 		dbManager,
 		i18n,
+		lifecycle,
 		logger,
 		ml,
 		server,
@@ -81,6 +84,12 @@ func (a *App) I18n() *support.I18n {
 	return a.i18n
 }
 
+// Lifecycle returns the app instance's lifecycle which emits the lifecycle
+// events to the in-process listeners and webhooks.
+func (a *App) Lifecycle() *support.Lifecycle {
+	return a.lifecycle
+}
+
 // Logger returns the app instance's logger.
 func (a *App) Logger() *support.Logger {
 	return a.logger
@@ -103,6 +112,10 @@ func (a *App) Worker() *worker.Engine {
 
 // Run starts running the app instance.
 func (a *App) Run() error {
+	if a.config.HTTPDocsEnabled {
+		a.server.SetupDocs(pack.NewDocs(a.server, a.mailer, a.worker.JobTypes()))
+	}
+
 	a.server.ServeSPA("/", a.asset.Embedded())
 	a.server.ServeNoRoute()
 
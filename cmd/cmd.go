@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path"
 
+	"github.com/appist/appy/mailer"
 	"github.com/appist/appy/pack"
 	"github.com/appist/appy/record"
 	"github.com/appist/appy/support"
@@ -52,11 +53,11 @@ func NewCommand() *Command {
 }
 
 // NewAppCommand initializes Command instance without built-in commands.
-func NewAppCommand(asset *support.Asset, config *support.Config, dbManager *record.Engine, logger *support.Logger, server *pack.Server, worker *worker.Engine) *Command {
+func NewAppCommand(asset *support.Asset, config *support.Config, dbManager *record.Engine, lifecycle *support.Lifecycle, logger *support.Logger, ml *mailer.Engine, server *pack.Server, worker *worker.Engine) *Command {
 	cmd := NewCommand()
 	cmd.AddCommand(newDBCreateCommand(config, dbManager, logger))
 	cmd.AddCommand(newDBDropCommand(config, dbManager, logger))
-	cmd.AddCommand(newDBMigrateCommand(config, dbManager, logger))
+	cmd.AddCommand(newDBMigrateCommand(config, dbManager, lifecycle, logger))
 	cmd.AddCommand(newDBMigrateStatusCommand(config, dbManager, logger))
 	cmd.AddCommand(newDBRollbackCommand(config, dbManager, logger))
 	cmd.AddCommand(newDBSchemaLoadCommand(config, dbManager, logger))
@@ -67,8 +68,12 @@ func NewAppCommand(asset *support.Asset, config *support.Config, dbManager *reco
 	cmd.AddCommand(newMiddlewareCommand(config, logger, server))
 	cmd.AddCommand(newRoutesCommand(config, logger, server))
 	cmd.AddCommand(newSecretCommand(logger))
-	cmd.AddCommand(newServeCommand(dbManager, logger, server))
+	cmd.AddCommand(newServeCommand(dbManager, logger, server, worker))
+	cmd.AddCommand(newServiceInstallCommand(logger))
+	cmd.AddCommand(newServiceRunCommand(dbManager, logger, server, worker))
+	cmd.AddCommand(newServiceUninstallCommand(logger))
 	cmd.AddCommand(newSetupCommand(asset, config, dbManager, logger))
+	cmd.AddCommand(newShadowCompareCommand(logger))
 	cmd.AddCommand(newSSLSetupCommand(logger, server))
 	cmd.AddCommand(newSSLTearDownCommand(logger, server))
 	cmd.AddCommand(newTearDownCommand(asset, logger))
@@ -79,7 +84,9 @@ func NewAppCommand(asset *support.Asset, config *support.Config, dbManager *reco
 		cmd.AddCommand(newConfigDecCommand(config, logger))
 		cmd.AddCommand(newConfigEncCommand(config, logger))
 		cmd.AddCommand(newDBSchemaDumpCommand(config, dbManager, logger))
+		cmd.AddCommand(newGenDocsCommand(config, logger, ml, server, worker))
 		cmd.AddCommand(newGenMigrationCommand(config, dbManager, logger))
+		cmd.AddCommand(newGenSchemaCommand(config, logger, server))
 		cmd.AddCommand(newSecretRotateCommand(asset, config, logger))
 		cmd.AddCommand(newStartCommand(logger, server))
 	}
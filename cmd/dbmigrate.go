@@ -5,7 +5,7 @@ import (
 	"github.com/appist/appy/support"
 )
 
-func newDBMigrateCommand(config *support.Config, dbManager *record.Engine, logger *support.Logger) *Command {
+func newDBMigrateCommand(config *support.Config, dbManager *record.Engine, lifecycle *support.Lifecycle, logger *support.Logger) *Command {
 	var target string
 
 	cmd := &Command{
@@ -48,6 +48,7 @@ func newDBMigrateCommand(config *support.Config, dbManager *record.Engine, logge
 				}
 
 				logger.Infof("Migrating '%s' database... DONE", target)
+				lifecycle.Emit(support.LifecycleEventMigrate, support.H{"databases": []string{target}})
 
 				if support.IsDebugBuild() {
 					logger.Infof("")
@@ -64,7 +65,7 @@ func newDBMigrateCommand(config *support.Config, dbManager *record.Engine, logge
 				return
 			}
 
-			runDBMigrateAll(config, dbManager, logger)
+			runDBMigrateAll(config, dbManager, lifecycle, logger)
 		},
 	}
 
@@ -72,7 +73,9 @@ func newDBMigrateCommand(config *support.Config, dbManager *record.Engine, logge
 	return cmd
 }
 
-func runDBMigrateAll(config *support.Config, dbManager *record.Engine, logger *support.Logger) {
+func runDBMigrateAll(config *support.Config, dbManager *record.Engine, lifecycle *support.Lifecycle, logger *support.Logger) {
+	migrated := []string{}
+
 	for name, db := range dbManager.Databases() {
 		if db.Config().Replica {
 			continue
@@ -92,6 +95,7 @@ func runDBMigrateAll(config *support.Config, dbManager *record.Engine, logger *s
 		}
 
 		logger.Infof("Migrating '%s' database... DONE", name)
+		migrated = append(migrated, name)
 
 		if support.IsDebugBuild() {
 			logger.Infof("")
@@ -105,4 +109,6 @@ func runDBMigrateAll(config *support.Config, dbManager *record.Engine, logger *s
 			logger.Infof("Dumping schema for '%s' database... DONE", name)
 		}
 	}
+
+	lifecycle.Emit(support.LifecycleEventMigrate, support.H{"databases": migrated})
 }
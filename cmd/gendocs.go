@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/appist/appy/mailer"
+	"github.com/appist/appy/pack"
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/worker"
+)
+
+func newGenDocsCommand(config *support.Config, logger *support.Logger, ml *mailer.Engine, server *pack.Server, worker *worker.Engine) *Command {
+	var output string
+
+	cmd := &Command{
+		Use:   "gen:docs",
+		Short: "Generate the static documentation site from the routes, GraphQL schemas, background jobs and mailer templates (only available in debug build)",
+		Run: func(cmd *Command, args []string) {
+			if len(config.Errors()) > 0 {
+				logger.Fatal(config.Errors()[0])
+			}
+
+			docs := pack.NewDocs(server, ml, worker.JobTypes())
+			if err := docs.Write(output); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Generating the docs into '%s'... DONE", output)
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "tmp/docs", "The directory to generate the static documentation site into")
+	return cmd
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/appist/appy/pack"
+	"github.com/appist/appy/support"
+)
+
+var (
+	generatedSchemaFileRegex = regexp.MustCompile(`^(connect|delete|get|head|options|patch|post|put|trace)_[^.]+\.(request|response)\.json$`)
+)
+
+func newGenSchemaCommand(config *support.Config, logger *support.Logger, server *pack.Server) *Command {
+	var output string
+
+	cmd := &Command{
+		Use:   "gen:schema",
+		Short: "Generate the JSON schemas of the routes' request/response body and the OpenAPI doc from the structs setup via `SetRouteSchema` (only available in debug build)",
+		Run: func(cmd *Command, args []string) {
+			if len(config.Errors()) > 0 {
+				logger.Fatal(config.Errors()[0])
+			}
+
+			if err := os.MkdirAll(output, 0777); err != nil {
+				logger.Fatal(err)
+			}
+
+			// Remove the previously generated schemas so that the removed routes
+			// don't leave any stale schema behind, without touching the other
+			// files in the output directory, i.e. --output docs.
+			stale, err := filepath.Glob(filepath.Join(output, "*.json"))
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			for _, file := range stale {
+				if !isGeneratedSchemaFile(filepath.Base(file)) {
+					continue
+				}
+
+				if err := os.Remove(file); err != nil {
+					logger.Fatal(err)
+				}
+			}
+
+			docs := pack.NewDocs(server, nil, nil)
+			for _, route := range docs.Routes {
+				schemas := map[string]*support.JSONSchema{
+					"request":  route.Request,
+					"response": route.Response,
+				}
+
+				for kind, schema := range schemas {
+					if schema == nil {
+						continue
+					}
+
+					filename := filepath.Join(output, schemaFilename(route.Method, route.Path)+"."+kind+".json")
+					if err := ioutil.WriteFile(filename, []byte(schema.String()+"\n"), 0644); err != nil {
+						logger.Fatal(err)
+					}
+				}
+			}
+
+			openAPI, err := docs.OpenAPI()
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			if err := ioutil.WriteFile(filepath.Join(output, "openapi.json"), append(openAPI, '\n'), 0644); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Generating the schemas into '%s'... DONE", output)
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "tmp/schema", "The directory to generate the JSON schemas and OpenAPI doc into")
+	return cmd
+}
+
+// schemaFilename returns the route's schema filename without the extension,
+// e.g. "POST /users/:id/*action" => "post_users_id_action".
+func schemaFilename(method, path string) string {
+	name := strings.NewReplacer(":", "", "*", "", "/", "_").Replace(strings.Trim(path, "/"))
+	if name == "" {
+		name = "root"
+	}
+
+	return strings.ToLower(method) + "_" + name
+}
+
+// isGeneratedSchemaFile checks if the file is generated by `gen:schema`, i.e.
+// "post_users.request.json" or "openapi.json".
+func isGeneratedSchemaFile(name string) bool {
+	return name == "openapi.json" || generatedSchemaFileRegex.MatchString(name)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/appist/appy/test"
+)
+
+type genSchemaSuite struct {
+	test.Suite
+}
+
+func (s *genSchemaSuite) TestSchemaFilename() {
+	tt := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", "/", "get_root"},
+		{"POST", "/users", "post_users"},
+		{"PATCH", "/users/:id/*action", "patch_users_id_action"},
+	}
+
+	for _, tc := range tt {
+		s.Equal(tc.expected, schemaFilename(tc.method, tc.path))
+	}
+}
+
+func (s *genSchemaSuite) TestIsGeneratedSchemaFile() {
+	tt := []struct {
+		name     string
+		expected bool
+	}{
+		{"openapi.json", true},
+		{"get_root.response.json", true},
+		{"post_users.request.json", true},
+		{"patch_users_id_action.response.json", true},
+		{"package.json", false},
+		{"post_users.json", false},
+		{"users.request.json", false},
+		{"fetch_users.request.json", false},
+		{"openapi.json.bak", false},
+	}
+
+	for _, tc := range tt {
+		s.Equal(tc.expected, isGeneratedSchemaFile(tc.name), tc.name)
+	}
+}
+
+func TestGenSchemaSuite(t *testing.T) {
+	test.Run(t, new(genSchemaSuite))
+}
@@ -7,44 +7,97 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/appist/appy/pack"
 	"github.com/appist/appy/record"
 	"github.com/appist/appy/support"
+	"github.com/appist/appy/worker"
 )
 
-func newServeCommand(dbManager *record.Engine, logger *support.Logger, server *pack.Server) *Command {
+func newServeCommand(dbManager *record.Engine, logger *support.Logger, server *pack.Server, worker *worker.Engine) *Command {
 	return &Command{
 		Use:   "serve",
 		Short: "Run the HTTP/HTTPS web server without `webpack-dev-server`",
 		Run: func(cmd *Command, args []string) {
-			if len(server.Config().Errors()) > 0 {
-				logger.Fatal(server.Config().Errors()[0])
-			}
+			checkServe(dbManager, logger, server)
+			serve(dbManager, logger, server, worker)
+		},
+	}
+}
 
-			if len(dbManager.Errors()) > 0 {
-				logger.Fatal(dbManager.Errors()[0])
-			}
+func checkServe(dbManager *record.Engine, logger *support.Logger, server *pack.Server) {
+	if len(server.Config().Errors()) > 0 {
+		logger.Fatal(server.Config().Errors()[0])
+	}
 
-			if server.Config().HTTPSSLEnabled && !server.IsSSLCertExisted() {
-				logger.Fatal("HTTP_SSL_ENABLED is set to true without SSL certs, please generate using `go run . ssl:setup` first.")
-			}
+	if len(dbManager.Errors()) > 0 {
+		logger.Fatal(dbManager.Errors()[0])
+	}
 
-			serve(dbManager, logger, server)
-		},
+	if server.Config().HTTPSSLEnabled && !server.IsSSLCertExisted() {
+		logger.Fatal("HTTP_SSL_ENABLED is set to true without SSL certs, please generate using `go run . ssl:setup` first.")
 	}
 }
 
-func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server) {
-	httpDone := make(chan bool, 1)
+func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server, worker *worker.Engine) {
 	httpQuit := make(chan os.Signal, 1)
 	signal.Notify(httpQuit, os.Interrupt)
 	signal.Notify(httpQuit, syscall.SIGTERM)
 
+	if len(maintenanceSignals) > 0 {
+		maintenance := make(chan os.Signal, 1)
+		for sig := range maintenanceSignals {
+			signal.Notify(maintenance, sig)
+		}
+
+		go handleMaintenanceSignals(server, maintenance)
+	}
+
+	serveUntil(dbManager, logger, server, worker, httpQuit)
+}
+
+// handleMaintenanceSignals puts the server into or out of the maintenance
+// mode upon the signals so that the operators can trigger it without
+// restarting the server, i.e. `kill -USR1 <pid>`.
+func handleMaintenanceSignals(server *pack.Server, signals <-chan os.Signal) {
+	for sig := range signals {
+		if maintenanceSignals[sig] {
+			server.EnterMaintenance()
+			continue
+		}
+
+		server.ExitMaintenance()
+	}
+}
+
+// serveUntil runs the HTTP/HTTPS web server until the quit channel receives a
+// signal which is either from the OS or the service manager, i.e. Windows
+// service control manager. With WORKER_PROVIDER=kv, the background jobs are
+// processed within the same process since the embedded key-value store can
+// only be opened by a single process.
+func serveUntil(dbManager *record.Engine, logger *support.Logger, server *pack.Server, worker *worker.Engine, httpQuit <-chan os.Signal) {
+	httpDone := make(chan bool, 1)
+	purgeDone := make(chan struct{})
+	warmupCtx, cancelWarmup := context.WithCancel(context.Background())
+
 	go func() {
 		<-httpQuit
+		cancelWarmup()
+		close(purgeDone)
 		logger.Infof("* Gracefully shutting down the server within %s...", server.Config().HTTPGracefulShutdownTimeout)
 
+		ctx, cancel := context.WithTimeout(context.Background(), server.Config().HTTPGracefulShutdownTimeout)
+		defer cancel()
+
+		if server.Lifecycle() != nil {
+			server.Lifecycle().EmitContext(ctx, support.LifecycleEventShutdown, nil)
+		}
+
+		if server.Config().WorkerProvider == "kv" {
+			worker.Stop()
+		}
+
 		for _, db := range dbManager.Databases() {
 			err := db.Close()
 			if err != nil {
@@ -54,9 +107,6 @@ func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server
 
 		// TODO: Allow graceful handling from the app.
 
-		ctx, cancel := context.WithTimeout(context.Background(), server.Config().HTTPGracefulShutdownTimeout)
-		defer cancel()
-
 		if err := server.HTTP().Shutdown(ctx); err != nil {
 			logger.Fatal(err)
 		}
@@ -77,6 +127,16 @@ func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server
 		}
 	}
 
+	if server.Config().WorkerProvider == "kv" {
+		if err := worker.Start(); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if usesKVStore(server.Config()) && server.Config().KVStorePurgeInterval > 0 {
+		go purgeKVStore(logger, server.Config(), purgeDone)
+	}
+
 	for _, info := range server.Info() {
 		if strings.Contains(info, "* Listening on") {
 			logger.Info(dbManager.Info())
@@ -85,6 +145,19 @@ func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server
 		logger.Info(info)
 	}
 
+	// The server starts listening before the warmup so that the health check
+	// can report that it isn't ready yet instead of refusing the connection.
+	go func() {
+		if err := server.Warmup(warmupCtx); err != nil && warmupCtx.Err() != nil {
+			return
+		}
+
+		if server.Lifecycle() != nil {
+			hosts, _ := server.Hosts()
+			server.Lifecycle().Emit(support.LifecycleEventBoot, support.H{"hosts": hosts})
+		}
+	}()
+
 	go func() {
 		if server.Config().HTTPSSLEnabled {
 			err := server.HTTPS().ListenAndServeTLS(server.Config().HTTPSSLCertPath+"/cert.pem", server.Config().HTTPSSLCertPath+"/key.pem")
@@ -101,3 +174,34 @@ func serve(dbManager *record.Engine, logger *support.Logger, server *pack.Server
 
 	<-httpDone
 }
+
+// usesKVStore returns true if any provider is configured to use the embedded
+// key-value store.
+func usesKVStore(config *support.Config) bool {
+	return config.GQLAPQCacheProvider == "kv" || config.HTTPSessionProvider == "kv" || config.WorkerProvider == "kv"
+}
+
+// purgeKVStore periodically deletes the expired keys in the embedded key-value
+// store until the done channel is closed since bbolt doesn't support TTL and
+// the expired keys that are never read again would stay in the file forever.
+func purgeKVStore(logger *support.Logger, config *support.Config, done <-chan struct{}) {
+	store, err := support.OpenKVStore(config.KVStorePath)
+	if err != nil {
+		logger.Errorf("[KV] unable to open the store at '%s' for purging the expired keys: %s", config.KVStorePath, err)
+		return
+	}
+
+	ticker := time.NewTicker(config.KVStorePurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := store.PurgeAllExpired(); err != nil {
+				logger.Errorf("[KV] unable to purge the expired keys: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// maintenanceSignals maps the signals that the `serve` command listens to for
+// entering (true) or exiting (false) the maintenance mode.
+var maintenanceSignals = map[os.Signal]bool{
+	syscall.SIGUSR1: true,
+	syscall.SIGUSR2: false,
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/pack"
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+)
+
+type serveSuite struct {
+	test.Suite
+}
+
+func (s *serveSuite) SetupTest() {
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+}
+
+func (s *serveSuite) TearDownTest() {
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *serveSuite) TestHandleMaintenanceSignals() {
+	if len(maintenanceSignals) == 0 {
+		s.T().Skip("maintenance signals are not supported")
+	}
+
+	logger, _, _ := support.NewTestLogger()
+	asset := support.NewAsset(nil, "")
+	server := pack.NewServer(asset, support.NewConfig(asset, logger), logger)
+
+	signals := make(chan os.Signal)
+	go handleMaintenanceSignals(server, signals)
+	defer close(signals)
+
+	for sig, enter := range maintenanceSignals {
+		signals <- sig
+		s.Eventually(func() bool { return server.IsMaintenance() == enter }, time.Second, time.Millisecond)
+	}
+}
+
+func TestServeSuite(t *testing.T) {
+	test.Run(t, new(serveSuite))
+}
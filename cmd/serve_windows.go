@@ -0,0 +1,8 @@
+package cmd
+
+import (
+	"os"
+)
+
+// maintenanceSignals is empty as Windows doesn't support SIGUSR1/SIGUSR2.
+var maintenanceSignals = map[os.Signal]bool{}
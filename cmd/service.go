@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/appist/appy/pack"
+	"github.com/appist/appy/record"
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/worker"
+)
+
+// serviceConfig specifies how the server runner is registered with the
+// service manager, i.e. launchd on macOS or the service control manager on
+// Windows.
+type serviceConfig struct {
+	name        string
+	description string
+	executable  string
+	args        []string
+	env         []string
+	workingDir  string
+	user        bool
+}
+
+var (
+	errServiceNotSupported = errors.New("the service is only supported on darwin(launchd) and windows(service control manager)")
+)
+
+func init() {
+	// The service manager starts the service in its own directory, i.e. the
+	// system directory on Windows, which has to be changed before the config
+	// is loaded from the relative paths, i.e. ".env.*" and KV_STORE_PATH.
+	if wd := serviceWorkingDir(os.Args[1:]); wd != "" {
+		if err := os.Chdir(wd); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// serviceWorkingDir returns the --working-dir flag value of the `service:run`
+// command which is the directory that `service:install` is run at.
+func serviceWorkingDir(args []string) string {
+	if len(args) == 0 || args[0] != "service:run" {
+		return ""
+	}
+
+	for i, arg := range args {
+		if arg == "--working-dir" && i+1 < len(args) {
+			return args[i+1]
+		}
+
+		if strings.HasPrefix(arg, "--working-dir=") {
+			return strings.TrimPrefix(arg, "--working-dir=")
+		}
+	}
+
+	return ""
+}
+
+func newServiceConfig(name string, env []string, user bool) (*serviceConfig, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	executable, err = filepath.Abs(executable)
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = getCommandName()
+	}
+
+	serviceEnv := []string{"APPY_ENV=" + os.Getenv("APPY_ENV")}
+	for _, val := range env {
+		if !strings.Contains(val, "=") {
+			return nil, errors.New("please provide the environment variable in KEY=VALUE format, e.g. --env HTTP_PORT=3000")
+		}
+
+		serviceEnv = setServiceEnv(serviceEnv, val)
+	}
+
+	return &serviceConfig{
+		name:        name,
+		description: support.DESCRIPTION,
+		executable:  executable,
+		args:        []string{"service:run", "--name", name, "--working-dir", wd},
+		env:         serviceEnv,
+		workingDir:  wd,
+		user:        user,
+	}, nil
+}
+
+// setServiceEnv replaces the environment variable with the same key instead of
+// appending a duplicate which the service control manager on Windows resolves
+// to the first one, so that --env APPY_ENV=production always wins.
+func setServiceEnv(env []string, val string) []string {
+	key := strings.SplitN(val, "=", 2)[0]
+
+	for i, existing := range env {
+		if strings.SplitN(existing, "=", 2)[0] == key {
+			env[i] = val
+			return env
+		}
+	}
+
+	return append(env, val)
+}
+
+func newServiceInstallCommand(logger *support.Logger) *Command {
+	var (
+		env  []string
+		name string
+		user bool
+	)
+
+	cmd := &Command{
+		Use:   "service:install",
+		Short: "Install the HTTP/HTTPS web server as a managed service with launchd on macOS or the service control manager on Windows",
+		Run: func(cmd *Command, args []string) {
+			config, err := newServiceConfig(name, env, user)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Installing the '%s' service...", config.name)
+
+			if err := installService(config); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Installing the '%s' service... DONE", config.name)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&env, "env", []string{}, "The environment variable in KEY=VALUE format to run the service with, e.g. APPY_MASTER_KEY=...")
+	cmd.Flags().StringVar(&name, "name", "", "The service name (default: the binary name)")
+	cmd.Flags().BoolVar(&user, "user", false, "Install as the current user's launch agent instead of the system-wide launch daemon (only available on macOS)")
+	return cmd
+}
+
+func newServiceUninstallCommand(logger *support.Logger) *Command {
+	var (
+		name string
+		user bool
+	)
+
+	cmd := &Command{
+		Use:   "service:uninstall",
+		Short: "Stop and uninstall the HTTP/HTTPS web server managed service that is installed via `service:install`",
+		Run: func(cmd *Command, args []string) {
+			config, err := newServiceConfig(name, nil, user)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Uninstalling the '%s' service...", config.name)
+
+			if err := uninstallService(config); err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Uninstalling the '%s' service... DONE", config.name)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "The service name (default: the binary name)")
+	cmd.Flags().BoolVar(&user, "user", false, "Uninstall the current user's launch agent instead of the system-wide launch daemon (only available on macOS)")
+	return cmd
+}
+
+func newServiceRunCommand(dbManager *record.Engine, logger *support.Logger, server *pack.Server, worker *worker.Engine) *Command {
+	var (
+		name       string
+		workingDir string
+	)
+
+	cmd := &Command{
+		Use:   "service:run",
+		Short: "Run the HTTP/HTTPS web server under the service manager, only to be invoked by launchd or the service control manager",
+		Run: func(cmd *Command, args []string) {
+			config, err := newServiceConfig(name, nil, false)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			checkServe(dbManager, logger, server)
+
+			err = runService(config, func(quit <-chan os.Signal) {
+				serveUntil(dbManager, logger, server, worker, quit)
+			})
+			if err != nil {
+				logger.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "The service name (default: the binary name)")
+	// The working directory is changed in init() before the config is loaded.
+	cmd.Flags().StringVar(&workingDir, "working-dir", "", "The directory to run the service in which is recorded by `service:install` (default: the current directory)")
+	return cmd
+}
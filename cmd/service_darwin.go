@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/template"
+)
+
+func installService(config *serviceConfig) error {
+	plistPath, err := launchdPlistPath(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(plistPath); err == nil {
+		return fmt.Errorf("the service '%s' is already installed at '%s'", config.name, plistPath)
+	}
+
+	logPath, err := launchdLogPath(config)
+	if err != nil {
+		return err
+	}
+
+	plist, err := launchdPlist(config, logPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return err
+	}
+
+	// The plist might contain the secrets passed via --env, i.e.
+	// APPY_MASTER_KEY, which should only be readable by the owner.
+	if err := ioutil.WriteFile(plistPath, plist, 0600); err != nil {
+		return err
+	}
+
+	return launchctl("load", "-w", plistPath)
+}
+
+func uninstallService(config *serviceConfig) error {
+	plistPath, err := launchdPlistPath(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
+		return fmt.Errorf("the service '%s' isn't installed at '%s'", config.name, plistPath)
+	}
+
+	if err := launchctl("unload", "-w", plistPath); err != nil {
+		return err
+	}
+
+	return os.Remove(plistPath)
+}
+
+// runService serves until launchd stops the service with SIGTERM.
+func runService(config *serviceConfig, run func(quit <-chan os.Signal)) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, syscall.SIGTERM)
+
+	run(quit)
+	return nil
+}
+
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func launchdPlistPath(config *serviceConfig) (string, error) {
+	if !config.user {
+		return filepath.Join("/Library/LaunchDaemons", config.name+".plist"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, "Library", "LaunchAgents", config.name+".plist"), nil
+}
+
+func launchdLogPath(config *serviceConfig) (string, error) {
+	if !config.user {
+		return filepath.Join("/Library/Logs", config.name+".log"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, "Library", "Logs", config.name+".log"), nil
+}
+
+func launchdPlist(config *serviceConfig, logPath string) ([]byte, error) {
+	env := map[string]string{}
+	for _, val := range config.env {
+		splits := strings.SplitN(val, "=", 2)
+		env[splits[0]] = splits[1]
+	}
+
+	tpl, err := template.New("plist").Funcs(template.FuncMap{
+		"xml": func(val string) (string, error) {
+			var buf bytes.Buffer
+			if err := xml.EscapeText(&buf, []byte(val)); err != nil {
+				return "", err
+			}
+
+			return buf.String(), nil
+		},
+	}).Parse(launchdPlistTpl)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]interface{}{
+		"args":       config.args,
+		"env":        env,
+		"executable": config.executable,
+		"logPath":    logPath,
+		"name":       config.name,
+		"workingDir": config.workingDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+const launchdPlistTpl = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .executable}}</string>{{range .args}}
+		<string>{{xml .}}</string>{{end}}
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>{{range $key, $val := .env}}
+		<key>{{xml $key}}</key>
+		<string>{{xml $val}}</string>{{end}}
+	</dict>
+	<key>WorkingDirectory</key>
+	<string>{{xml .workingDir}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>{{xml .logPath}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .logPath}}</string>
+</dict>
+</plist>
+`
@@ -0,0 +1,18 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package cmd
+
+import "os"
+
+func installService(config *serviceConfig) error {
+	return errServiceNotSupported
+}
+
+func uninstallService(config *serviceConfig) error {
+	return errServiceNotSupported
+}
+
+func runService(config *serviceConfig, run func(quit <-chan os.Signal)) error {
+	return errServiceNotSupported
+}
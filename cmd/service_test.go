@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/appist/appy/test"
+)
+
+type serviceSuite struct {
+	test.Suite
+}
+
+func (s *serviceSuite) SetupTest() {
+	os.Setenv("APPY_ENV", "development")
+}
+
+func (s *serviceSuite) TearDownTest() {
+	os.Unsetenv("APPY_ENV")
+}
+
+func (s *serviceSuite) TestNewServiceConfig() {
+	tt := []struct {
+		env      []string
+		expected []string
+	}{
+		{[]string{}, []string{"APPY_ENV=development"}},
+		{[]string{"HTTP_PORT=3000"}, []string{"APPY_ENV=development", "HTTP_PORT=3000"}},
+		{[]string{"APPY_ENV=production", "HTTP_PORT=3000"}, []string{"APPY_ENV=production", "HTTP_PORT=3000"}},
+		{[]string{"HTTP_PORT=3000", "HTTP_PORT=4000", "FOO=a=b"}, []string{"APPY_ENV=development", "HTTP_PORT=4000", "FOO=a=b"}},
+	}
+
+	for _, tc := range tt {
+		config, err := newServiceConfig("myapp", tc.env, false)
+		s.Nil(err)
+		s.Equal("myapp", config.name)
+		s.Equal([]string{"service:run", "--name", "myapp", "--working-dir", config.workingDir}, config.args)
+		s.Equal(tc.expected, config.env)
+	}
+
+	_, err := newServiceConfig("myapp", []string{"HTTP_PORT"}, false)
+	s.NotNil(err)
+}
+
+func (s *serviceSuite) TestServiceWorkingDir() {
+	tt := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{}, ""},
+		{[]string{"serve", "--working-dir", "/srv/myapp"}, ""},
+		{[]string{"service:run", "--name", "myapp"}, ""},
+		{[]string{"service:run", "--name", "myapp", "--working-dir"}, ""},
+		{[]string{"service:run", "--name", "myapp", "--working-dir", "/srv/myapp"}, "/srv/myapp"},
+		{[]string{"service:run", "--working-dir=C:\\myapp", "--name", "myapp"}, "C:\\myapp"},
+	}
+
+	for _, tc := range tt {
+		s.Equal(tc.expected, serviceWorkingDir(tc.args))
+	}
+}
+
+func TestServiceSuite(t *testing.T) {
+	test.Run(t, new(serviceSuite))
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsService handles the service control manager's requests and shuts
+// down the server gracefully when it is requested to stop.
+type windowsService struct {
+	run func(quit <-chan os.Signal)
+}
+
+func (ws *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	quit := make(chan os.Signal, 1)
+	done := make(chan bool, 1)
+
+	go func() {
+		ws.run(quit)
+		close(done)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-done:
+			changes <- svc.Status{State: svc.Stopped}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				quit <- os.Interrupt
+				<-done
+
+				changes <- svc.Status{State: svc.Stopped}
+				return false, 0
+			}
+		}
+	}
+}
+
+func installService(config *serviceConfig) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(config.name); err == nil {
+		s.Close()
+		return fmt.Errorf("the service '%s' is already installed", config.name)
+	}
+
+	s, err := m.CreateService(config.name, config.executable, mgr.Config{
+		DisplayName: config.name,
+		Description: config.description,
+		StartType:   mgr.StartAutomatic,
+	}, config.args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// The service control manager passes the "Environment" multi-string value
+	// in the service's registry key to the service process.
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+config.name, registry.SET_VALUE)
+	if err != nil {
+		s.Delete()
+		return err
+	}
+	defer key.Close()
+
+	if err := key.SetStringsValue("Environment", config.env); err != nil {
+		s.Delete()
+		return err
+	}
+
+	return s.Start()
+}
+
+func uninstallService(config *serviceConfig) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(config.name)
+	if err != nil {
+		return fmt.Errorf("the service '%s' isn't installed", config.name)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+
+	if status.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return err
+		}
+
+		// Wait for the server to shut down gracefully before deleting the
+		// service so that the binary can be replaced right after.
+		for i := 0; i < 60 && status.State != svc.Stopped; i++ {
+			time.Sleep(time.Second)
+
+			if status, err = s.Query(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.Delete()
+}
+
+// runService runs the server under the service control manager. If it is
+// invoked from an interactive session, i.e. command prompt, it behaves like
+// `serve` for debugging purpose.
+func runService(config *serviceConfig, run func(quit <-chan os.Signal)) error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return err
+	}
+
+	if interactive {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt)
+		signal.Notify(quit, syscall.SIGTERM)
+
+		run(quit)
+		return nil
+	}
+
+	// The service control manager starts the service in the system directory
+	// which is already changed to the --working-dir by init() in service.go.
+	return svc.Run(config.name, &windowsService{run: run})
+}
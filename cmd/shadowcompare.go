@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appist/appy/support"
+)
+
+type (
+	// shadowRequest is a single request in the recorded corpus which is a file
+	// with one JSON object per line, i.e.
+	// {"method":"GET","path":"/users?page=1","header":{"Accept":"application/json"},"body":""}
+	shadowRequest struct {
+		Method string            `json:"method"`
+		Path   string            `json:"path"`
+		Header map[string]string `json:"header"`
+		Body   string            `json:"body"`
+	}
+
+	shadowResponse struct {
+		status int
+		header http.Header
+		body   []byte
+	}
+
+	shadowResult struct {
+		Request shadowRequest `json:"request"`
+		Diffs   []string      `json:"diffs"`
+		Error   string        `json:"error,omitempty"`
+	}
+
+	shadowReport struct {
+		Base       string         `json:"base"`
+		Target     string         `json:"target"`
+		Total      int            `json:"total"`
+		Matched    int            `json:"matched"`
+		Mismatched int            `json:"mismatched"`
+		Failed     int            `json:"failed"`
+		Results    []shadowResult `json:"results"`
+	}
+
+	shadowComparer struct {
+		base          string
+		target        string
+		client        *http.Client
+		ignoreHeaders map[string]bool
+		ignoreFields  []string
+		ignoreRegexes []*regexp.Regexp
+	}
+)
+
+var (
+	shadowDefaultIgnoreHeaders = []string{"Content-Length", "Date", "Set-Cookie", "X-Request-Id"}
+)
+
+func newShadowCompareCommand(logger *support.Logger) *Command {
+	var (
+		base, corpus, report, target             string
+		concurrency                              int
+		ignoreFields, ignoreHeaders, ignoreRegex []string
+		timeout                                  time.Duration
+	)
+
+	cmd := &Command{
+		Use:   "shadow:compare",
+		Short: "Replay a recorded request corpus against 2 running versions and report the status/headers/body differences",
+		Run: func(cmd *Command, args []string) {
+			if base == "" || target == "" || corpus == "" {
+				logger.Fatal("please provide --base, --target and --corpus, e.g. --base http://0.0.0.0:3000 --target http://0.0.0.0:3001 --corpus tmp/corpus.jsonl")
+			}
+
+			requests, err := readShadowCorpus(corpus)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			comparer, err := newShadowComparer(base, target, timeout, append(shadowDefaultIgnoreHeaders, ignoreHeaders...), ignoreFields, ignoreRegex)
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			logger.Infof("Replaying %d requests against '%s' and '%s'...", len(requests), base, target)
+			result := comparer.run(requests, concurrency)
+
+			for _, res := range result.Results {
+				if res.Error != "" {
+					logger.Errorf("%s %s: %s", res.Request.Method, res.Request.Path, res.Error)
+					continue
+				}
+
+				for _, diff := range res.Diffs {
+					logger.Warnf("%s %s: %s", res.Request.Method, res.Request.Path, diff)
+				}
+			}
+
+			logger.Infof("Replaying %d requests... DONE (matched: %d, mismatched: %d, failed: %d)", result.Total, result.Matched, result.Mismatched, result.Failed)
+
+			if report != "" {
+				data, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					logger.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(report, data, 0644); err != nil {
+					logger.Fatal(err)
+				}
+
+				logger.Infof("Report is written to '%s'", report)
+			}
+
+			if result.Mismatched > 0 || result.Failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&base, "base", "", "The base URL of the currently deployed version, e.g. http://0.0.0.0:3000")
+	cmd.Flags().StringVar(&target, "target", "", "The base URL of the version to cut over to, e.g. http://0.0.0.0:3001")
+	cmd.Flags().StringVar(&corpus, "corpus", "", "The recorded request corpus file with one JSON request per line")
+	cmd.Flags().StringVar(&report, "report", "", "The file path to write the JSON report to")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 4, "The number of requests to replay concurrently")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "How long to wait for each response")
+	cmd.Flags().StringSliceVar(&ignoreHeaders, "ignore-header", []string{}, "The response header to ignore in addition to "+strings.Join(shadowDefaultIgnoreHeaders, ", "))
+	cmd.Flags().StringSliceVar(&ignoreFields, "ignore-field", []string{}, "The dot-separated JSON response body field to ignore, e.g. data.user.updatedAt")
+	cmd.Flags().StringSliceVar(&ignoreRegex, "ignore-regex", []string{}, "The regular expression of the response body content to ignore, e.g. csrf-token=\"[^\"]+\"")
+	return cmd
+}
+
+func readShadowCorpus(path string) ([]shadowRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	requests := []shadowRequest{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		req := shadowRequest{}
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return nil, fmt.Errorf("unable to parse line %d in '%s': %s", line, path, err)
+		}
+
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+
+		requests = append(requests, req)
+	}
+
+	return requests, scanner.Err()
+}
+
+func newShadowComparer(base, target string, timeout time.Duration, ignoreHeaders, ignoreFields, ignoreRegex []string) (*shadowComparer, error) {
+	comparer := &shadowComparer{
+		base:   strings.TrimSuffix(base, "/"),
+		target: strings.TrimSuffix(target, "/"),
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		ignoreHeaders: map[string]bool{},
+		ignoreFields:  ignoreFields,
+	}
+
+	for _, header := range ignoreHeaders {
+		comparer.ignoreHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	for _, pattern := range ignoreRegex {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		comparer.ignoreRegexes = append(comparer.ignoreRegexes, regex)
+	}
+
+	return comparer, nil
+}
+
+func (sc *shadowComparer) run(requests []shadowRequest, concurrency int) *shadowReport {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]shadowResult, len(requests))
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for idx := range queue {
+				results[idx] = sc.compare(requests[idx])
+			}
+		}()
+	}
+
+	for idx := range requests {
+		queue <- idx
+	}
+	close(queue)
+	wg.Wait()
+
+	report := &shadowReport{
+		Base:    sc.base,
+		Target:  sc.target,
+		Total:   len(requests),
+		Results: []shadowResult{},
+	}
+
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			report.Failed++
+		case len(result.Diffs) > 0:
+			report.Mismatched++
+		default:
+			report.Matched++
+			continue
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report
+}
+
+func (sc *shadowComparer) compare(req shadowRequest) shadowResult {
+	result := shadowResult{Request: req, Diffs: []string{}}
+
+	baseResp, err := sc.replay(sc.base, req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	targetResp, err := sc.replay(sc.target, req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if baseResp.status != targetResp.status {
+		result.Diffs = append(result.Diffs, fmt.Sprintf("status: %d != %d", baseResp.status, targetResp.status))
+	}
+
+	result.Diffs = append(result.Diffs, sc.diffHeaders(baseResp.header, targetResp.header)...)
+
+	if diff := sc.diffBodies(baseResp.body, targetResp.body); diff != "" {
+		result.Diffs = append(result.Diffs, diff)
+	}
+
+	return result
+}
+
+func (sc *shadowComparer) replay(host string, req shadowRequest) (*shadowResponse, error) {
+	httpReq, err := http.NewRequest(req.Method, host+req.Path, strings.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, val := range req.Header {
+		// The "Host" header is ignored by the HTTP client which only sends
+		// the request's Host.
+		if http.CanonicalHeaderKey(key) == "Host" {
+			httpReq.Host = val
+			continue
+		}
+
+		httpReq.Header.Set(key, val)
+	}
+
+	resp, err := sc.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shadowResponse{resp.StatusCode, resp.Header, body}, nil
+}
+
+func (sc *shadowComparer) diffHeaders(base, target http.Header) []string {
+	keys := map[string]bool{}
+	for key := range base {
+		keys[http.CanonicalHeaderKey(key)] = true
+	}
+
+	for key := range target {
+		keys[http.CanonicalHeaderKey(key)] = true
+	}
+
+	sortedKeys := []string{}
+	for key := range keys {
+		if !sc.ignoreHeaders[key] {
+			sortedKeys = append(sortedKeys, key)
+		}
+	}
+	sort.Strings(sortedKeys)
+
+	diffs := []string{}
+	for _, key := range sortedKeys {
+		baseVal := strings.Join(base.Values(key), ", ")
+		targetVal := strings.Join(target.Values(key), ", ")
+
+		if baseVal != targetVal {
+			diffs = append(diffs, fmt.Sprintf("header '%s': '%s' != '%s'", key, baseVal, targetVal))
+		}
+	}
+
+	return diffs
+}
+
+func (sc *shadowComparer) diffBodies(base, target []byte) string {
+	var baseJSON, targetJSON interface{}
+
+	if json.Unmarshal(base, &baseJSON) == nil && json.Unmarshal(target, &targetJSON) == nil {
+		for _, field := range sc.ignoreFields {
+			deleteJSONField(baseJSON, strings.Split(field, "."))
+			deleteJSONField(targetJSON, strings.Split(field, "."))
+		}
+
+		if reflect.DeepEqual(baseJSON, targetJSON) {
+			return ""
+		}
+
+		base, _ = json.MarshalIndent(baseJSON, "", "  ")
+		target, _ = json.MarshalIndent(targetJSON, "", "  ")
+	}
+
+	for _, regex := range sc.ignoreRegexes {
+		base = regex.ReplaceAll(base, []byte("<ignored>"))
+		target = regex.ReplaceAll(target, []byte("<ignored>"))
+	}
+
+	if bytes.Equal(base, target) {
+		return ""
+	}
+
+	baseLines := strings.Split(string(base), "\n")
+	targetLines := strings.Split(string(target), "\n")
+
+	for i := 0; i < len(baseLines) || i < len(targetLines); i++ {
+		var baseLine, targetLine string
+
+		if i < len(baseLines) {
+			baseLine = baseLines[i]
+		}
+
+		if i < len(targetLines) {
+			targetLine = targetLines[i]
+		}
+
+		if baseLine != targetLine {
+			return fmt.Sprintf("body at line %d: '%s' != '%s'", i+1, truncateShadowLine(baseLine), truncateShadowLine(targetLine))
+		}
+	}
+
+	return ""
+}
+
+// deleteJSONField removes the field at the path, i.e. ["data", "updatedAt"],
+// from the decoded JSON. The array elements are traversed with the same path.
+func deleteJSONField(data interface{}, path []string) {
+	switch val := data.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(val, path[0])
+			return
+		}
+
+		deleteJSONField(val[path[0]], path[1:])
+	case []interface{}:
+		for _, elem := range val {
+			deleteJSONField(elem, path)
+		}
+	}
+}
+
+func truncateShadowLine(line string) string {
+	if len(line) > 120 {
+		return line[:120] + "..."
+	}
+
+	return line
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/test"
+)
+
+type shadowCompareSuite struct {
+	test.Suite
+}
+
+func (s *shadowCompareSuite) TestReadShadowCorpus() {
+	dir, err := ioutil.TempDir("", "shadow")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	tt := []struct {
+		corpus   string
+		requests []shadowRequest
+		err      string
+	}{
+		{"", []shadowRequest{}, ""},
+		{
+			"{\"path\":\"/users\"}\n\n  \n{\"method\":\"POST\",\"path\":\"/users\",\"header\":{\"Host\":\"example.com\"},\"body\":\"{}\"}\n",
+			[]shadowRequest{
+				{Method: "GET", Path: "/users"},
+				{Method: "POST", Path: "/users", Header: map[string]string{"Host": "example.com"}, Body: "{}"},
+			},
+			"",
+		},
+		{"{\"path\":\"/users\"}\n{\"path\":", nil, "unable to parse line 2 in"},
+	}
+
+	for _, tc := range tt {
+		path := filepath.Join(dir, "corpus.jsonl")
+		s.Nil(ioutil.WriteFile(path, []byte(tc.corpus), 0644))
+
+		requests, err := readShadowCorpus(path)
+		if tc.err != "" {
+			s.Contains(err.Error(), tc.err)
+			continue
+		}
+
+		s.Nil(err)
+		s.Equal(tc.requests, requests)
+	}
+
+	_, err = readShadowCorpus(filepath.Join(dir, "missing.jsonl"))
+	s.NotNil(err)
+}
+
+func (s *shadowCompareSuite) TestDiffHeaders() {
+	sc, err := newShadowComparer("", "", time.Second, shadowDefaultIgnoreHeaders, nil, nil)
+	s.Nil(err)
+
+	tt := []struct {
+		base   http.Header
+		target http.Header
+		diffs  []string
+	}{
+		{http.Header{}, http.Header{}, []string{}},
+		{http.Header{"Date": {"Mon"}, "X-Request-Id": {"1"}}, http.Header{"Date": {"Tue"}, "X-Request-Id": {"2"}}, []string{}},
+		{http.Header{"Content-Type": {"text/html"}}, http.Header{"Content-Type": {"text/html"}}, []string{}},
+		{
+			http.Header{"Content-Type": {"text/html"}, "Vary": {"Origin", "Accept"}},
+			http.Header{"Content-Type": {"application/json"}, "X-Foo": {"bar"}},
+			[]string{
+				"header 'Content-Type': 'text/html' != 'application/json'",
+				"header 'Vary': 'Origin, Accept' != ''",
+				"header 'X-Foo': '' != 'bar'",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		s.Equal(tc.diffs, sc.diffHeaders(tc.base, tc.target))
+	}
+}
+
+func (s *shadowCompareSuite) TestDiffBodies() {
+	tt := []struct {
+		ignoreFields []string
+		ignoreRegex  []string
+		base         string
+		target       string
+		diff         string
+	}{
+		{nil, nil, `{"id":1,"name":"foo"}`, `{"name":"foo","id":1}`, ""},
+		{nil, nil, `{"id":1}`, `{"id":2}`, `body at line 2: '  "id": 1' != '  "id": 2'`},
+		{[]string{"updatedAt"}, nil, `{"id":1,"updatedAt":"a"}`, `{"id":1,"updatedAt":"b"}`, ""},
+		{
+			[]string{"data.users.updatedAt"}, nil,
+			`{"data":{"users":[{"id":1,"updatedAt":"a"},{"id":2,"updatedAt":"b"}]}}`,
+			`{"data":{"users":[{"id":1,"updatedAt":"c"},{"id":2,"updatedAt":"d"}]}}`,
+			"",
+		},
+		{
+			[]string{"data.users.updatedAt"}, nil,
+			`{"data":{"users":[{"id":1,"updatedAt":"a"}]}}`,
+			`{"data":{"users":[{"id":3,"updatedAt":"c"}]}}`,
+			`body at line 5: '        "id": 1' != '        "id": 3'`,
+		},
+		{nil, []string{`csrf-token="[^"]+"`}, `<meta csrf-token="abc">`, `<meta csrf-token="def">`, ""},
+		{nil, []string{`\d{4}-\d{2}-\d{2}`}, `{"at":"2020-01-01"}`, `{"at":"2020-01-02"}`, ""},
+		{nil, nil, "<p>foo</p>\n<p>bar</p>", "<p>foo</p>\n<p>baz</p>", "body at line 2: '<p>bar</p>' != '<p>baz</p>'"},
+		{nil, nil, "foo", "foo\nbar", "body at line 2: '' != 'bar'"},
+		{[]string{"id"}, nil, `{"id":1}`, "not json", "body at line 1: '{\"id\":1}' != 'not json'"},
+	}
+
+	for _, tc := range tt {
+		sc, err := newShadowComparer("", "", time.Second, nil, tc.ignoreFields, tc.ignoreRegex)
+		s.Nil(err)
+		s.Equal(tc.diff, sc.diffBodies([]byte(tc.base), []byte(tc.target)))
+	}
+
+	_, err := newShadowComparer("", "", time.Second, nil, nil, []string{"("})
+	s.NotNil(err)
+}
+
+func (s *shadowCompareSuite) TestDeleteJSONField() {
+	tt := []struct {
+		data     interface{}
+		path     []string
+		expected interface{}
+	}{
+		{map[string]interface{}{"a": 1, "b": 2}, []string{"a"}, map[string]interface{}{"b": 2}},
+		{map[string]interface{}{"a": 1}, []string{"c"}, map[string]interface{}{"a": 1}},
+		{map[string]interface{}{"a": 1}, []string{"a", "b"}, map[string]interface{}{"a": 1}},
+		{
+			map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}},
+			[]string{"a", "b"},
+			map[string]interface{}{"a": map[string]interface{}{"c": 2}},
+		},
+		{
+			[]interface{}{map[string]interface{}{"a": 1, "b": 2}, map[string]interface{}{"a": 3}, "foo"},
+			[]string{"a"},
+			[]interface{}{map[string]interface{}{"b": 2}, map[string]interface{}{}, "foo"},
+		},
+		{"foo", []string{"a"}, "foo"},
+	}
+
+	for _, tc := range tt {
+		deleteJSONField(tc.data, tc.path)
+		s.Equal(tc.expected, tc.data)
+	}
+}
+
+func (s *shadowCompareSuite) TestRun() {
+	handler := func(version string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/host":
+				w.Write([]byte(r.Host))
+			case "/version":
+				w.Write([]byte(version))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}
+
+	base := httptest.NewServer(handler("v1"))
+	defer base.Close()
+
+	target := httptest.NewServer(handler("v2"))
+	defer target.Close()
+
+	sc, err := newShadowComparer(base.URL, target.URL+"/", time.Second, shadowDefaultIgnoreHeaders, nil, nil)
+	s.Nil(err)
+
+	report := sc.run([]shadowRequest{
+		{Method: "GET", Path: "/host", Header: map[string]string{"host": "example.com"}},
+		{Method: "GET", Path: "/missing"},
+		{Method: "GET", Path: "/version"},
+		{Method: "BAD METHOD", Path: "/"},
+	}, 2)
+
+	s.Equal(4, report.Total)
+	s.Equal(2, report.Matched)
+	s.Equal(1, report.Mismatched)
+	s.Equal(1, report.Failed)
+	s.Equal("/version", report.Results[0].Request.Path)
+	s.Equal([]string{"body at line 1: 'v1' != 'v2'"}, report.Results[0].Diffs)
+
+	resp, err := sc.replay(base.URL, shadowRequest{Method: "GET", Path: "/host", Header: map[string]string{"Host": "example.com"}})
+	s.Nil(err)
+	s.Equal("example.com", string(resp.body))
+}
+
+func TestShadowCompareSuite(t *testing.T) {
+	test.Run(t, new(shadowCompareSuite))
+}
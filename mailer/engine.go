@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/smtp"
 	"net/textproto"
+	"path/filepath"
 	"strings"
 
 	"github.com/CloudyKit/jet"
@@ -17,6 +18,7 @@ import (
 // Engine provides the capability to parse/render email template and send it
 // out via SMTP protocol.
 type Engine struct {
+	asset      *support.Asset
 	config     *support.Config
 	deliveries []*Mail
 	i18n       *support.I18n
@@ -32,6 +34,7 @@ func NewEngine(asset *support.Asset, config *support.Config, i18n *support.I18n,
 	ve.SetGlobalFuncs(viewFuncs)
 
 	return &Engine{
+		asset:    asset,
 		config:   config,
 		i18n:     i18n,
 		previews: map[string]*Mail{},
@@ -130,6 +133,47 @@ func (e *Engine) Previews() map[string]*Mail {
 	return e.previews
 }
 
+// Templates returns all the mailer templates in the "mailers" view directory
+// with their available formats, e.g. "mailers/user/welcome" => ["html", "txt"].
+func (e *Engine) Templates() (map[string][]string, error) {
+	templates := map[string][]string{}
+
+	if err := e.readTemplates("mailers", templates); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (e *Engine) readTemplates(dir string, templates map[string][]string) error {
+	fis, err := e.asset.ReadDir(e.asset.Layout().View() + "/" + dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		name := dir + "/" + fi.Name()
+
+		if fi.IsDir() {
+			if err := e.readTemplates(name, templates); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		if ext != ".html" && ext != ".txt" {
+			continue
+		}
+
+		name = strings.TrimSuffix(name, ext)
+		templates[name] = append(templates[name], strings.TrimPrefix(ext, "."))
+	}
+
+	return nil
+}
+
 func (e *Engine) content(locale, name, ext string, obj interface{}) ([]byte, error) {
 	set := e.viewEngine.HTMLSet()
 
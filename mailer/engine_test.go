@@ -201,6 +201,18 @@ func (s *mailerSuite) TestMailerWithTestAppyEnv() {
 	s.Contains(deliveries[0].Text, "Hi, John Doe! You have 2 messages.")
 }
 
+func (s *mailerSuite) TestTemplates() {
+	mailer := NewEngine(s.asset, s.config, s.i18n, s.logger, nil)
+
+	templates, err := mailer.Templates()
+	s.Nil(err)
+	s.Equal(map[string][]string{
+		"mailers/user/error":          {"html"},
+		"mailers/user/reset_password": {"html"},
+		"mailers/user/verify_account": {"html", "txt"},
+	}, templates)
+}
+
 func TestMailerSuite(t *testing.T) {
 	test.Run(t, new(mailerSuite))
 }
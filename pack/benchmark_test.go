@@ -33,7 +33,7 @@ func newServer() *Server {
 	server.Use(mdwGzip(config))
 	server.Use(mdwHealthCheck(config.HTTPHealthCheckPath, server))
 	server.Use(mdwPrerender(config, logger))
-	server.Use(mdwCSRF(config, logger))
+	server.Use(mdwCSRF(config, logger, server))
 	server.Use(mdwSecure(config))
 	server.Use(mdwAPIOnly())
 	server.Use(mdwSession(config))
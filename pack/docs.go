@@ -0,0 +1,307 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appist/appy/mailer"
+	"github.com/appist/appy/support"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+type (
+	// Docs is the documentation of the application's API and background jobs
+	// which is built from the route metadata, GraphQL SDL, job definitions and
+	// mailer templates.
+	Docs struct {
+		GeneratedAt time.Time
+		GraphQL     []DocsGraphQL
+		Jobs        []string
+		Mailers     []DocsMailer
+		OpenAPIURL  string
+		Routes      []DocsRoute
+		Title       string
+	}
+
+	// DocsGraphQL represents a GraphQL endpoint with its schema definition.
+	DocsGraphQL struct {
+		Path string
+		SDL  string
+	}
+
+	// DocsMailer represents a mailer template with its available formats.
+	DocsMailer struct {
+		Template string
+		Subject  string
+		Formats  []string
+	}
+
+	// DocsRoute represents a HTTP route with its description and the JSON
+	// schemas of its request/response body.
+	DocsRoute struct {
+		Method      string
+		Path        string
+		Handler     string
+		Description string
+		Request     *support.JSONSchema
+		Response    *support.JSONSchema
+	}
+)
+
+const docsOpenAPIFilename = "openapi.json"
+
+// NewDocs builds the documentation from the server routes, the GraphQL
+// schemas setup via SetupGraphQL, the background job types and the mailer
+// templates.
+func NewDocs(server *Server, ml *mailer.Engine, jobTypes []string) *Docs {
+	docs := &Docs{
+		GeneratedAt: time.Now().UTC(),
+		GraphQL:     []DocsGraphQL{},
+		Jobs:        append([]string{}, jobTypes...),
+		Mailers:     []DocsMailer{},
+		OpenAPIURL:  docsOpenAPIFilename,
+		Routes:      []DocsRoute{},
+		Title:       "API Documentation",
+	}
+	sort.Strings(docs.Jobs)
+
+	for _, route := range server.Routes() {
+		docsRoute := DocsRoute{
+			Method:      route.Method,
+			Path:        route.Path,
+			Handler:     route.Handler,
+			Description: server.routeDocs[route.Method+" "+route.Path],
+		}
+
+		if schema, ok := server.routeSchemas[route.Method+" "+route.Path]; ok {
+			docsRoute.Request = schema.Request
+			docsRoute.Response = schema.Response
+		}
+
+		docs.Routes = append(docs.Routes, docsRoute)
+	}
+
+	sort.SliceStable(docs.Routes, func(i, j int) bool {
+		if docs.Routes[i].Path == docs.Routes[j].Path {
+			return docs.Routes[i].Method < docs.Routes[j].Method
+		}
+
+		return docs.Routes[i].Path < docs.Routes[j].Path
+	})
+
+	for path, schema := range server.gqlSchemas {
+		if schema == nil {
+			continue
+		}
+
+		docs.GraphQL = append(docs.GraphQL, DocsGraphQL{
+			Path: path,
+			SDL:  formatGQLSchema(schema.Schema()),
+		})
+	}
+
+	sort.Slice(docs.GraphQL, func(i, j int) bool {
+		return docs.GraphQL[i].Path < docs.GraphQL[j].Path
+	})
+
+	if ml != nil {
+		// The mailer templates are optional as there might be no mailer views
+		// in the project.
+		templates, _ := ml.Templates()
+
+		for name, formats := range templates {
+			mail := DocsMailer{Template: name, Formats: formats}
+
+			if preview, ok := ml.Previews()[name]; ok {
+				mail.Subject = preview.Subject
+			}
+
+			docs.Mailers = append(docs.Mailers, mail)
+		}
+
+		sort.Slice(docs.Mailers, func(i, j int) bool {
+			return docs.Mailers[i].Template < docs.Mailers[j].Template
+		})
+	}
+
+	return docs
+}
+
+// Render renders the documentation into a single HTML page.
+func (d *Docs) Render() ([]byte, error) {
+	tpl, err := template.New("docs").Parse(docsTpl())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Write generates the static documentation site into the directory which can
+// be hosted by any static file server.
+func (d *Docs) Write(dir string) error {
+	content, err := d.Render()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), content, 0644); err != nil {
+		return err
+	}
+
+	for _, gql := range d.GraphQL {
+		filename := filepath.Join(dir, docsGQLFilename(gql.Path))
+
+		if err := ioutil.WriteFile(filename, []byte(gql.SDL), 0644); err != nil {
+			return err
+		}
+	}
+
+	openAPI, err := d.OpenAPI()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, docsOpenAPIFilename), openAPI, 0644)
+}
+
+// OpenAPI generates the OpenAPI 3.0 document from the routes with the JSON
+// schemas setup via SetRouteSchema.
+func (d *Docs) OpenAPI() ([]byte, error) {
+	paths := map[string]map[string]interface{}{}
+
+	for _, route := range d.Routes {
+		// CONNECT isn't a valid operation in the OpenAPI specification.
+		if route.Method == "CONNECT" {
+			continue
+		}
+
+		path, params := openAPIPath(route.Path)
+		if _, ok := paths[path]; !ok {
+			paths[path] = map[string]interface{}{}
+		}
+
+		operation := map[string]interface{}{}
+		if route.Description != "" {
+			operation["summary"] = route.Description
+		}
+
+		if len(params) > 0 {
+			parameters := []map[string]interface{}{}
+
+			for _, param := range params {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     param,
+					"in":       "path",
+					"required": true,
+					"schema":   &support.JSONSchema{Type: "string"},
+				})
+			}
+
+			operation["parameters"] = parameters
+		}
+
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  openAPIJSONContent(route.Request),
+			}
+		}
+
+		response := map[string]interface{}{"description": "OK"}
+		if route.Response != nil {
+			response["content"] = openAPIJSONContent(route.Response)
+		}
+
+		operation["responses"] = map[string]interface{}{"200": response}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   d.Title,
+			"version": d.GeneratedAt.Format("20060102150405"),
+		},
+		"paths": paths,
+	}, "", "  ")
+}
+
+// openAPIPath converts the router path into the OpenAPI path template, e.g.
+// "/users/:id/*action" => "/users/{id}/{action}", with its parameter names.
+func openAPIPath(path string) (string, []string) {
+	params := []string{}
+	segments := strings.Split(path, "/")
+
+	for idx, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[idx] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func openAPIJSONContent(schema *support.JSONSchema) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// docsGQLFilename returns the SDL filename for the GraphQL endpoint, e.g.
+// "/graphql" => "graphql.graphql", "/api/graphql" => "api_graphql.graphql".
+func docsGQLFilename(path string) string {
+	name := strings.ReplaceAll(strings.Trim(path, "/"), "/", "_")
+	if name == "" {
+		name = "schema"
+	}
+
+	return name + ".graphql"
+}
+
+// formatGQLSchema formats the schema into SDL without the built-in types and
+// directives that are part of the GraphQL specification.
+func formatGQLSchema(schema *ast.Schema) string {
+	if schema == nil {
+		return ""
+	}
+
+	userSchema := *schema
+	userSchema.Types = map[string]*ast.Definition{}
+	userSchema.Directives = map[string]*ast.DirectiveDefinition{}
+
+	for name, def := range schema.Types {
+		if !def.BuiltIn {
+			userSchema.Types[name] = def
+		}
+	}
+
+	for name, def := range schema.Directives {
+		if def.Position == nil || def.Position.Src == nil || !def.Position.Src.BuiltIn {
+			userSchema.Directives[name] = def
+		}
+	}
+
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatSchema(&userSchema)
+
+	return buf.String()
+}
@@ -0,0 +1,177 @@
+package pack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type docsSuite struct {
+	test.Suite
+	asset  *support.Asset
+	config *support.Config
+	logger *support.Logger
+	server *Server
+}
+
+func (s *docsSuite) SetupTest() {
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	s.logger, _, _ = support.NewTestLogger()
+	s.asset = support.NewAsset(nil, "")
+	s.config = support.NewConfig(s.asset, s.logger)
+	s.server = NewServer(s.asset, s.config, s.logger)
+	s.server.GET("/users", func(c *Context) {
+		c.JSON(http.StatusOK, H{})
+	})
+	s.server.SetRouteDoc("GET", "/users", "List the users.")
+	s.server.PUT("/users/:id", func(c *Context) {
+		c.JSON(http.StatusOK, H{})
+	})
+	s.server.SetRouteSchema("PUT", "/users/:id", struct {
+		Name string `json:"name" binding:"required"`
+	}{}, nil)
+	s.server.SetupGraphQL("/graphql", &graphql.ExecutableSchemaMock{
+		SchemaFunc: func() *ast.Schema {
+			return gqlparser.MustLoadSchema(&ast.Source{Input: "type Query { hello: String! }"})
+		},
+	}, nil)
+}
+
+func (s *docsSuite) TearDownTest() {
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *docsSuite) TestNewDocs() {
+	docs := NewDocs(s.server, nil, []string{"user.sync", "mailer.send"})
+
+	s.Equal([]string{"mailer.send", "user.sync"}, docs.Jobs)
+	s.Equal(0, len(docs.Mailers))
+	s.Equal("/graphql", docs.Routes[0].Path)
+	s.Equal("", docs.Routes[0].Description)
+	s.Equal("GET", docs.Routes[len(docs.Routes)-2].Method)
+	s.Equal("/users", docs.Routes[len(docs.Routes)-2].Path)
+	s.Equal("List the users.", docs.Routes[len(docs.Routes)-2].Description)
+	s.Nil(docs.Routes[len(docs.Routes)-2].Request)
+	s.Equal("/users/:id", docs.Routes[len(docs.Routes)-1].Path)
+	s.Equal([]string{"name"}, docs.Routes[len(docs.Routes)-1].Request.Required)
+	s.Nil(docs.Routes[len(docs.Routes)-1].Response)
+
+	s.Equal(1, len(docs.GraphQL))
+	s.Equal("/graphql", docs.GraphQL[0].Path)
+	s.Contains(docs.GraphQL[0].SDL, "type Query {")
+	s.NotContains(docs.GraphQL[0].SDL, "__Schema")
+	s.NotContains(docs.GraphQL[0].SDL, "directive @skip")
+
+	content, err := docs.Render()
+	s.Nil(err)
+	s.Contains(string(content), "List the users.")
+	s.Contains(string(content), "user.sync")
+	s.Contains(string(content), "hello: String!")
+}
+
+func (s *docsSuite) TestOpenAPI() {
+	content, err := NewDocs(s.server, nil, nil).OpenAPI()
+	s.Nil(err)
+
+	var doc struct {
+		OpenAPI string
+		Paths   map[string]map[string]struct {
+			Summary    string
+			Parameters []struct {
+				Name string
+				In   string
+			}
+			RequestBody struct {
+				Content map[string]struct {
+					Schema support.JSONSchema
+				}
+			}
+		}
+	}
+	s.Nil(json.Unmarshal(content, &doc))
+	s.Equal("3.0.3", doc.OpenAPI)
+	s.Equal("List the users.", doc.Paths["/users"]["get"].Summary)
+	s.NotContains(doc.Paths["/graphql"], "connect")
+
+	put := doc.Paths["/users/{id}"]["put"]
+	s.Equal("id", put.Parameters[0].Name)
+	s.Equal("path", put.Parameters[0].In)
+	s.Equal([]string{"name"}, put.RequestBody.Content["application/json"].Schema.Required)
+}
+
+func (s *docsSuite) TestWrite() {
+	dir, err := ioutil.TempDir("", "docs")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	docs := NewDocs(s.server, nil, nil)
+	s.Nil(docs.Write(dir))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+	s.Nil(err)
+	s.Contains(string(content), "/users")
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "graphql.graphql"))
+	s.Nil(err)
+	s.Contains(string(content), "type Query {")
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "openapi.json"))
+	s.Nil(err)
+	s.Contains(string(content), `"/users/{id}"`)
+}
+
+func (s *docsSuite) TestSetupDocs() {
+	{
+		server := NewServer(s.asset, s.config, s.logger)
+		server.SetupDocs(NewDocs(s.server, nil, nil))
+
+		w := server.TestHTTPRequest("GET", "/docs", nil, nil)
+		s.Equal(http.StatusNotFound, w.Code)
+	}
+
+	{
+		s.config.HTTPDocsUsername = "admin"
+		s.config.HTTPDocsPassword = "secret"
+		server := NewServer(s.asset, s.config, s.logger)
+		server.SetupDocs(NewDocs(s.server, nil, nil))
+
+		w := server.TestHTTPRequest("GET", "/docs", nil, nil)
+		s.Equal(http.StatusUnauthorized, w.Code)
+		s.Equal(`Basic realm="docs"`, w.Header().Get("WWW-Authenticate"))
+
+		w = server.TestHTTPRequest("GET", "/docs", H{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:wrong"))}, nil)
+		s.Equal(http.StatusUnauthorized, w.Code)
+
+		auth := H{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret"))}
+		w = server.TestHTTPRequest("GET", "/docs", auth, nil)
+		s.Equal(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), "List the users.")
+
+		w = server.TestHTTPRequest("GET", "/docs/openapi.json", auth, nil)
+		s.Equal(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), `"openapi": "3.0.3"`)
+
+		w = server.TestHTTPRequest("GET", "/docs/graphql.graphql", auth, nil)
+		s.Equal(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), "type Query {")
+	}
+}
+
+func TestDocsSuite(t *testing.T) {
+	test.Run(t, new(docsSuite))
+}
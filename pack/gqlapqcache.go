@@ -0,0 +1,48 @@
+package pack
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/appist/appy/support"
+)
+
+const gqlAPQCacheBucket = "gql_apq"
+
+// gqlAPQKVCache persists the APQ in the embedded key-value store so that they
+// survive the server restarts. Each APQ expires after the TTL and at most size
+// APQ are kept with the oldest ones evicted first.
+type gqlAPQKVCache struct {
+	logger *support.Logger
+	store  *support.KVStore
+	size   int
+	ttl    time.Duration
+}
+
+var _ graphql.Cache = &gqlAPQKVCache{}
+
+func (c *gqlAPQKVCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	query, err := c.store.Get(gqlAPQCacheBucket, key)
+	if err != nil {
+		c.logger.Error(err)
+		return nil, false
+	}
+
+	if query == nil {
+		return nil, false
+	}
+
+	return string(query), true
+}
+
+func (c *gqlAPQKVCache) Add(ctx context.Context, key string, value interface{}) {
+	query, ok := value.(string)
+	if !ok {
+		return
+	}
+
+	if err := c.store.SetCapped(gqlAPQCacheBucket, key, []byte(query), c.ttl, c.size); err != nil {
+		c.logger.Error(err)
+	}
+}
@@ -0,0 +1,37 @@
+package pack
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+var (
+	gqlIntrospectionCtxKey = ContextKey("gqlIntrospection")
+)
+
+// gqlIntrospection enables the GraphQL introspection only if the request is
+// allowed to by GQL_INTROSPECTION_ENABLED or GQL_INTROSPECTION_ALLOWLIST.
+type gqlIntrospection struct{}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = gqlIntrospection{}
+
+func (e gqlIntrospection) ExtensionName() string {
+	return "Introspection"
+}
+
+func (e gqlIntrospection) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e gqlIntrospection) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if allowed, ok := ctx.Value(gqlIntrospectionCtxKey).(bool); ok && allowed {
+		rc.DisableIntrospection = false
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package sessionstore
 
 import (
+	"net/http"
+
 	ginsessions "github.com/gin-contrib/sessions"
 	gorsessions "github.com/gorilla/sessions"
 )
@@ -35,3 +37,26 @@ func (s *CookieStore) KeyPrefix() string {
 // SetKeyPrefix doesn't do anything for cookie store.
 func (s *CookieStore) SetKeyPrefix(p string) {
 }
+
+// Track doesn't do anything for cookie store.
+func (s *CookieStore) Track(r *http.Request, session *gorsessions.Session) error {
+	return nil
+}
+
+// UserSessions isn't supported for cookie store as the sessions are only
+// stored in the browsers.
+func (s *CookieStore) UserSessions(userID string) ([]SessionInfo, error) {
+	return nil, ErrUserSessionsNotSupported
+}
+
+// RevokeUserSession isn't supported for cookie store as the sessions are only
+// stored in the browsers.
+func (s *CookieStore) RevokeUserSession(userID, sessionID string) error {
+	return ErrUserSessionsNotSupported
+}
+
+// RevokeUserSessions isn't supported for cookie store as the sessions are only
+// stored in the browsers.
+func (s *CookieStore) RevokeUserSessions(userID string) error {
+	return ErrUserSessionsNotSupported
+}
@@ -0,0 +1,340 @@
+package sessionstore
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appist/appy/support"
+	ginsessions "github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gorsessions "github.com/gorilla/sessions"
+)
+
+const kvBucket = "sessions"
+
+// KVStore stores sessions in the embedded key-value store.
+type KVStore struct {
+	store         *support.KVStore
+	Codecs        []securecookie.Codec
+	CookieOptions *gorsessions.Options // default configuration
+	DefaultMaxAge int                  // default TTL for a MaxAge == 0 session
+	maxLength     int
+	keyPrefix     string
+	serializer    SessionSerializer
+}
+
+// NewKVStore initializes a KVStore instance with the embedded key-value store.
+func NewKVStore(store *support.KVStore, keyPairs ...[]byte) Store {
+	return &KVStore{
+		store:  store,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		CookieOptions: &gorsessions.Options{
+			Path:   "/",
+			MaxAge: defaultCookieMaxAge,
+		},
+		DefaultMaxAge: 60 * 20,
+		maxLength:     4096,
+		keyPrefix:     "session:",
+		serializer:    GobSerializer{},
+	}
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (s *KVStore) Get(r *http.Request, name string) (*gorsessions.Session, error) {
+	return gorsessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the registry.
+func (s *KVStore) New(r *http.Request, name string) (*gorsessions.Session, error) {
+	var (
+		err error
+		ok  bool
+	)
+	session := gorsessions.NewSession(s, name)
+	options := *s.CookieOptions
+	session.Options = &options
+	session.IsNew = true
+	if c, errCookie := r.Cookie(name); errCookie == nil {
+		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+		if err == nil {
+			ok, err = s.load(session)
+			session.IsNew = !(err == nil && ok)
+
+			// Don't reuse the ID of the expired/revoked session.
+			if err == nil && !ok {
+				session.ID = ""
+			}
+		}
+	}
+
+	return session, err
+}
+
+// Options defines how the session cookie should be configured.
+func (s *KVStore) Options(options ginsessions.Options) {
+	s.CookieOptions = &gorsessions.Options{
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		SameSite: options.SameSite,
+		Secure:   options.Secure,
+		HttpOnly: options.HttpOnly,
+	}
+}
+
+// Save adds a single session to the response.
+func (s *KVStore) Save(r *http.Request, w http.ResponseWriter, session *gorsessions.Session) error {
+	// Marked for deletion.
+	if session.Options.MaxAge < 0 {
+		if err := s.store.Delete(kvBucket, s.keyPrefix+session.ID); err != nil {
+			return err
+		}
+
+		if err := s.untrack(session); err != nil {
+			return err
+		}
+
+		http.SetCookie(w, gorsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+
+	if err := s.save(session); err != nil {
+		return err
+	}
+
+	if err := s.track(r, session, true); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, gorsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// KeyPrefix returns the prefix for the key.
+func (s *KVStore) KeyPrefix() string {
+	return s.keyPrefix
+}
+
+// SetKeyPrefix sets the prefix for the key.
+func (s *KVStore) SetKeyPrefix(p string) {
+	s.keyPrefix = p
+}
+
+// Track records the session's device, IP and last seen time if it is
+// associated with a user via the UserIDKey session value. It doesn't do
+// anything if the session is expired, revoked or marked for deletion.
+func (s *KVStore) Track(r *http.Request, session *gorsessions.Session) error {
+	return s.track(r, session, false)
+}
+
+// track records the session info which is throttled by trackInterval unless
+// force is true, i.e. on Save which refreshes the session data's TTL.
+func (s *KVStore) track(r *http.Request, session *gorsessions.Session, force bool) error {
+	userID, ok := session.Values[UserIDKey].(string)
+	if !ok || userID == "" || session.ID == "" || s.maxAge(session) <= 0 {
+		return nil
+	}
+
+	prevInfo, err := s.sessionInfo(session.ID)
+	if err != nil {
+		return err
+	}
+
+	if !force && isRecentlyTracked(prevInfo, userID, r) {
+		return nil
+	}
+
+	// The session info shouldn't outlive the session data whose TTL is only
+	// refreshed on Save.
+	ttl, exists, err := s.store.TTL(kvBucket, s.keyPrefix+session.ID)
+	if err != nil || !exists {
+		return err
+	}
+
+	now := time.Now().UTC()
+	info := &SessionInfo{
+		ID:         session.ID,
+		UserID:     userID,
+		UserAgent:  r.UserAgent(),
+		IP:         requestIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	if prevInfo != nil && prevInfo.UserID == userID {
+		info.CreatedAt = prevInfo.CreatedAt
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Set(kvBucket, s.sessionInfoKey(session.ID), data, ttl); err != nil {
+		return err
+	}
+
+	// The user's sessions are indexed by the keys with the user prefix as
+	// there is no set data type in the key-value store.
+	return s.store.Set(kvBucket, s.userSessionsKey(userID)+session.ID, []byte(session.ID), ttl)
+}
+
+// UserSessions returns the user's active sessions which are sorted by the
+// last seen time in descending order.
+func (s *KVStore) UserSessions(userID string) ([]SessionInfo, error) {
+	keys, err := s.store.Keys(kvBucket, s.userSessionsKey(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []SessionInfo{}
+	for _, key := range keys {
+		sessionID := strings.TrimPrefix(key, s.userSessionsKey(userID))
+
+		info, err := s.sessionInfo(sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		_, exists, err := s.store.TTL(kvBucket, s.keyPrefix+sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Clean up the session that is expired or no longer belongs to the user.
+		if info == nil || info.UserID != userID || !exists {
+			keys := []string{key}
+			if info != nil && info.UserID == userID {
+				keys = append(keys, s.sessionInfoKey(sessionID))
+			}
+
+			if err := s.store.Delete(kvBucket, keys...); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		infos = append(infos, *info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastSeenAt.After(infos[j].LastSeenAt)
+	})
+
+	return infos, nil
+}
+
+// RevokeUserSession revokes the user's specific session. It doesn't do
+// anything if the session doesn't belong to the user.
+func (s *KVStore) RevokeUserSession(userID, sessionID string) error {
+	member, err := s.store.Get(kvBucket, s.userSessionsKey(userID)+sessionID)
+	if err != nil || len(member) == 0 {
+		return err
+	}
+
+	return s.store.Delete(kvBucket, s.keyPrefix+sessionID, s.sessionInfoKey(sessionID), s.userSessionsKey(userID)+sessionID)
+}
+
+// RevokeUserSessions revokes all the user's sessions.
+func (s *KVStore) RevokeUserSessions(userID string) error {
+	keys, err := s.store.Keys(kvBucket, s.userSessionsKey(userID))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range append([]string{}, keys...) {
+		sessionID := strings.TrimPrefix(key, s.userSessionsKey(userID))
+		keys = append(keys, s.keyPrefix+sessionID, s.sessionInfoKey(sessionID))
+	}
+
+	return s.store.Delete(kvBucket, keys...)
+}
+
+// save stores the session in the key-value store.
+func (s *KVStore) save(session *gorsessions.Session) error {
+	b, err := s.serializer.Serialize(session)
+	if err != nil {
+		return err
+	}
+
+	if s.maxLength != 0 && len(b) > s.maxLength {
+		return errors.New("the value to store into session is too big")
+	}
+
+	return s.store.Set(kvBucket, s.keyPrefix+session.ID, b, time.Duration(s.maxAge(session))*time.Second)
+}
+
+// load reads the session from the key-value store and returns true if there
+// is a session data.
+func (s *KVStore) load(session *gorsessions.Session) (bool, error) {
+	data, err := s.store.Get(kvBucket, s.keyPrefix+session.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(data) == 0 {
+		return false, nil // the session is either expired or revoked
+	}
+
+	return true, s.serializer.Deserialize(data, session)
+}
+
+// untrack removes the session from the user's active sessions.
+func (s *KVStore) untrack(session *gorsessions.Session) error {
+	userID, ok := session.Values[UserIDKey].(string)
+	if !ok || userID == "" || session.ID == "" {
+		return nil
+	}
+
+	return s.store.Delete(kvBucket, s.sessionInfoKey(session.ID), s.userSessionsKey(userID)+session.ID)
+}
+
+// sessionInfo returns the session's information or nil if it isn't tracked.
+func (s *KVStore) sessionInfo(sessionID string) (*SessionInfo, error) {
+	data, err := s.store.Get(kvBucket, s.sessionInfoKey(sessionID))
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	info := &SessionInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *KVStore) maxAge(session *gorsessions.Session) int {
+	if session.Options.MaxAge == 0 {
+		return s.DefaultMaxAge
+	}
+
+	return session.Options.MaxAge
+}
+
+func (s *KVStore) sessionInfoKey(sessionID string) string {
+	return s.keyPrefix + "info:" + sessionID
+}
+
+// userSessionsKey returns the prefix of the user's session index keys. The
+// user ID is hex-encoded so that the prefix of the user "1" doesn't match the
+// keys of the user "1:abc".
+func (s *KVStore) userSessionsKey(userID string) string {
+	return s.keyPrefix + "user:" + hex.EncodeToString([]byte(userID)) + ":"
+}
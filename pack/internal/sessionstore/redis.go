@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"encoding/base32"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	ginsessions "github.com/gin-contrib/sessions"
 	"github.com/go-redis/redis/v7"
@@ -106,6 +110,11 @@ func (s *RedisStore) New(r *http.Request, name string) (*gorsessions.Session, er
 		if err == nil {
 			ok, err = s.load(session)
 			session.IsNew = !(err == nil && ok) // not new if no error and data available
+
+			// Don't reuse the ID of the expired/revoked session.
+			if err == nil && !ok {
+				session.ID = ""
+			}
 		}
 	}
 
@@ -132,6 +141,10 @@ func (s *RedisStore) Save(r *http.Request, w http.ResponseWriter, session *gorse
 			return err
 		}
 
+		if err := s.untrack(session); err != nil {
+			return err
+		}
+
 		http.SetCookie(w, gorsessions.NewCookie(session.Name(), "", session.Options))
 		return nil
 	}
@@ -145,6 +158,10 @@ func (s *RedisStore) Save(r *http.Request, w http.ResponseWriter, session *gorse
 		return err
 	}
 
+	if err := s.track(r, session, true); err != nil {
+		return err
+	}
+
 	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
 	if err != nil {
 		return err
@@ -164,6 +181,152 @@ func (s *RedisStore) SetKeyPrefix(p string) {
 	s.keyPrefix = p
 }
 
+// Track records the session's device, IP and last seen time if it is
+// associated with a user via the UserIDKey session value. It doesn't do
+// anything if the session is expired, revoked or marked for deletion.
+func (s *RedisStore) Track(r *http.Request, session *gorsessions.Session) error {
+	return s.track(r, session, false)
+}
+
+// track records the session info which is throttled by trackInterval unless
+// force is true, i.e. on Save which refreshes the session data's TTL.
+func (s *RedisStore) track(r *http.Request, session *gorsessions.Session, force bool) error {
+	userID, ok := session.Values[UserIDKey].(string)
+	if !ok || userID == "" || session.ID == "" || s.maxAge(session) <= 0 {
+		return nil
+	}
+
+	prevInfo, err := s.sessionInfo(session.ID)
+	if err != nil {
+		return err
+	}
+
+	if !force && isRecentlyTracked(prevInfo, userID, r) {
+		return nil
+	}
+
+	// The session info shouldn't outlive the session data whose TTL is only
+	// refreshed on Save.
+	ttl, err := s.redisClient.PTTL(s.keyPrefix + session.ID).Result()
+	if err != nil {
+		return err
+	}
+
+	// -2 means that the session data doesn't exist and -1 means that it never
+	// expires.
+	if ttl == -2 {
+		return nil
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	now := time.Now().UTC()
+	info := &SessionInfo{
+		ID:         session.ID,
+		UserID:     userID,
+		UserAgent:  r.UserAgent(),
+		IP:         requestIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	if prevInfo != nil && prevInfo.UserID == userID {
+		info.CreatedAt = prevInfo.CreatedAt
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(s.sessionInfoKey(session.ID), data, ttl).Err(); err != nil {
+		return err
+	}
+
+	if err := s.redisClient.SAdd(s.userSessionsKey(userID), session.ID).Err(); err != nil {
+		return err
+	}
+
+	return s.redisClient.Expire(s.userSessionsKey(userID), time.Duration(s.maxAge(session))*time.Second).Err()
+}
+
+// UserSessions returns the user's active sessions which are sorted by the
+// last seen time in descending order.
+func (s *RedisStore) UserSessions(userID string) ([]SessionInfo, error) {
+	sessionIDs, err := s.redisClient.SMembers(s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []SessionInfo{}
+	for _, sessionID := range sessionIDs {
+		info, err := s.sessionInfo(sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		exists, err := s.redisClient.Exists(s.keyPrefix + sessionID).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Clean up the session that is expired or no longer belongs to the user.
+		if info == nil || info.UserID != userID || exists == 0 {
+			if err := s.redisClient.SRem(s.userSessionsKey(userID), sessionID).Err(); err != nil {
+				return nil, err
+			}
+
+			if info != nil && info.UserID == userID {
+				if err := s.redisClient.Del(s.sessionInfoKey(sessionID)).Err(); err != nil {
+					return nil, err
+				}
+			}
+
+			continue
+		}
+
+		infos = append(infos, *info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastSeenAt.After(infos[j].LastSeenAt)
+	})
+
+	return infos, nil
+}
+
+// RevokeUserSession revokes the user's specific session. It doesn't do
+// anything if the session doesn't belong to the user.
+func (s *RedisStore) RevokeUserSession(userID, sessionID string) error {
+	isMember, err := s.redisClient.SIsMember(s.userSessionsKey(userID), sessionID).Result()
+	if err != nil || !isMember {
+		return err
+	}
+
+	if err := s.redisClient.Del(s.keyPrefix+sessionID, s.sessionInfoKey(sessionID)).Err(); err != nil {
+		return err
+	}
+
+	return s.redisClient.SRem(s.userSessionsKey(userID), sessionID).Err()
+}
+
+// RevokeUserSessions revokes all the user's sessions.
+func (s *RedisStore) RevokeUserSessions(userID string) error {
+	sessionIDs, err := s.redisClient.SMembers(s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{s.userSessionsKey(userID)}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, s.keyPrefix+sessionID, s.sessionInfoKey(sessionID))
+	}
+
+	return s.redisClient.Del(keys...).Err()
+}
+
 // ping does an internal ping against a server to check if it is alive.
 func (s *RedisStore) ping() (bool, error) {
 	data, err := s.redisClient.Ping().Result()
@@ -185,12 +348,7 @@ func (s *RedisStore) save(session *gorsessions.Session) error {
 		return errors.New("the value to store into session is too big")
 	}
 
-	age := session.Options.MaxAge
-	if age == 0 {
-		age = s.DefaultMaxAge
-	}
-
-	_, err = s.redisClient.Do("SETEX", s.keyPrefix+session.ID, age, b).Result()
+	_, err = s.redisClient.Do("SETEX", s.keyPrefix+session.ID, s.maxAge(session), b).Result()
 	if err != nil {
 		return err
 	}
@@ -201,6 +359,10 @@ func (s *RedisStore) save(session *gorsessions.Session) error {
 // load reads the session from redis and returns true if there is a sessoin data in DB.
 func (s *RedisStore) load(session *gorsessions.Session) (bool, error) {
 	data, err := s.redisClient.Get(s.keyPrefix + session.ID).Result()
+	if err == redis.Nil {
+		return false, nil // the session is either expired or revoked
+	}
+
 	if err != nil {
 		return false, err
 	}
@@ -220,3 +382,61 @@ func (s *RedisStore) delete(session *gorsessions.Session) error {
 
 	return nil
 }
+
+// untrack removes the session from the user's active sessions.
+func (s *RedisStore) untrack(session *gorsessions.Session) error {
+	userID, ok := session.Values[UserIDKey].(string)
+	if !ok || userID == "" || session.ID == "" {
+		return nil
+	}
+
+	if err := s.redisClient.Del(s.sessionInfoKey(session.ID)).Err(); err != nil {
+		return err
+	}
+
+	return s.redisClient.SRem(s.userSessionsKey(userID), session.ID).Err()
+}
+
+// sessionInfo returns the session's information or nil if it isn't tracked.
+func (s *RedisStore) sessionInfo(sessionID string) (*SessionInfo, error) {
+	data, err := s.redisClient.Get(s.sessionInfoKey(sessionID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SessionInfo{}
+	if err := json.Unmarshal([]byte(data), info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (s *RedisStore) maxAge(session *gorsessions.Session) int {
+	if session.Options.MaxAge == 0 {
+		return s.DefaultMaxAge
+	}
+
+	return session.Options.MaxAge
+}
+
+func (s *RedisStore) sessionInfoKey(sessionID string) string {
+	return s.keyPrefix + "info:" + sessionID
+}
+
+func (s *RedisStore) userSessionsKey(userID string) string {
+	return s.keyPrefix + "user:" + userID
+}
+
+func requestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
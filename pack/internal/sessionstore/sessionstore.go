@@ -1,7 +1,29 @@
 package sessionstore
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	ginsessions "github.com/gin-contrib/sessions"
+	gorsessions "github.com/gorilla/sessions"
+)
+
+const (
+	// UserIDKey is the session value key that associates the session with a
+	// user.
+	UserIDKey = "_user_id"
+
+	// trackInterval indicates how often Track refreshes the last seen time of
+	// the session that is still on the same device and IP so that the session
+	// info isn't written on every request.
+	trackInterval = time.Minute
+)
+
+var (
+	// ErrUserSessionsNotSupported indicates the store is unable to list or
+	// revoke the user's sessions, i.e. CookieStore.
+	ErrUserSessionsNotSupported = errors.New("the session store doesn't support listing/revoking the user's sessions")
 )
 
 type (
@@ -14,5 +36,39 @@ type (
 
 		// SetKeyPrefix sets the prefix for the store key, not available for CookieStore.
 		SetKeyPrefix(p string)
+
+		// Track records the session's device, IP and last seen time if it is
+		// associated with a user and it isn't tracked within the last minute
+		// on the same device and IP, not available for CookieStore.
+		Track(r *http.Request, session *gorsessions.Session) error
+
+		// UserSessions returns the user's active sessions, not available for CookieStore.
+		UserSessions(userID string) ([]SessionInfo, error)
+
+		// RevokeUserSession revokes the user's specific session, not available for CookieStore.
+		RevokeUserSession(userID, sessionID string) error
+
+		// RevokeUserSessions revokes all the user's sessions, not available for CookieStore.
+		RevokeUserSessions(userID string) error
+	}
+
+	// SessionInfo contains the information of a user's active session.
+	SessionInfo struct {
+		ID         string    `json:"id"`
+		UserID     string    `json:"userID"`
+		UserAgent  string    `json:"userAgent"`
+		IP         string    `json:"ip"`
+		CreatedAt  time.Time `json:"createdAt"`
+		LastSeenAt time.Time `json:"lastSeenAt"`
 	}
 )
+
+// isRecentlyTracked checks if the session info is tracked within trackInterval
+// for the same user, device and IP.
+func isRecentlyTracked(info *SessionInfo, userID string, r *http.Request) bool {
+	return info != nil &&
+		info.UserID == userID &&
+		info.UserAgent == r.UserAgent() &&
+		info.IP == requestIP(r) &&
+		time.Since(info.LastSeenAt) < trackInterval
+}
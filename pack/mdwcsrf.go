@@ -14,6 +14,21 @@ import (
 	"github.com/gorilla/securecookie"
 )
 
+// CSRFStrategy indicates how the CSRF check verifies the unsafe requests.
+type CSRFStrategy string
+
+const (
+	// CSRFStrategyToken verifies the authenticity token that is sent via the
+	// "X-CSRF-Token" request header or the "authenticity_token" form field
+	// which works for all the browsers.
+	CSRFStrategyToken CSRFStrategy = "token"
+
+	// CSRFStrategyOrigin verifies the "Sec-Fetch-Site" or "Origin" request
+	// header sent by the modern browsers which doesn't require plumbing the
+	// authenticity token into the forms or SPA requests.
+	CSRFStrategyOrigin CSRFStrategy = "origin"
+)
+
 var (
 	mdwCSRFTokenLength                 = 32
 	mdwCSRFSecureCookie                *securecookie.SecureCookie
@@ -25,6 +40,7 @@ var (
 	errCSRFBadReferer                  = errors.New("the request referer is invalid")
 	errCSRFNoToken                     = errors.New("the CSRF token is missing")
 	errCSRFBadToken                    = errors.New("the CSRF token is invalid")
+	errCSRFCrossOrigin                 = errors.New("the request is cross-origin")
 	generateRandomBytes                = func(n int) ([]byte, error) {
 		b := make([]byte, n)
 		_, err := rand.Read(b)
@@ -40,16 +56,80 @@ func CSRFSkipCheck() HandlerFunc {
 	}
 }
 
-func mdwCSRF(config *support.Config, logger *support.Logger) HandlerFunc {
+func mdwCSRF(config *support.Config, logger *support.Logger, server *Server) HandlerFunc {
 	mdwCSRFSecureCookie = securecookie.New(config.HTTPCSRFSecret, nil)
 	mdwCSRFSecureCookie.SetSerializer(securecookie.JSONEncoder{})
 	mdwCSRFSecureCookie.MaxAge(config.HTTPCSRFCookieMaxAge)
 
 	return func(c *Context) {
+		path := ""
+		if c.Request.URL != nil {
+			path = c.Request.URL.Path
+		}
+
+		if server.csrfStrategy(path) == CSRFStrategyOrigin {
+			mdwCSRFOriginHandler(c, config, logger)
+			return
+		}
+
 		mdwCSRFHandler(c, config, logger)
 	}
 }
 
+// mdwCSRFOriginHandler rejects the unsafe cross-origin requests based on the
+// "Sec-Fetch-Site" request header, or the "Origin" request header for the
+// browsers that don't send "Sec-Fetch-Site". The requests without both
+// headers are not from the browsers and are allowed.
+func mdwCSRFOriginHandler(c *Context, config *support.Config, logger *support.Logger) {
+	if c.IsAPIOnly() {
+		c.Set(mdwCSRFSkipCheckCtxKey.String(), true)
+	}
+
+	skipCheck, exists := c.Get(mdwCSRFSkipCheckCtxKey.String())
+	if exists && skipCheck.(bool) {
+		c.Next()
+		return
+	}
+
+	r := c.Request
+	if !support.ArrayContains(mdwCSRFSafeMethods, r.Method) && !isSameOriginRequest(r, config) {
+		logger.Error(errCSRFCrossOrigin)
+		c.AbortWithError(http.StatusForbidden, errCSRFCrossOrigin)
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Origin")
+	c.Writer.Header().Add("Vary", "Sec-Fetch-Site")
+	c.Next()
+}
+
+func isSameOriginRequest(r *http.Request, config *support.Config) bool {
+	origin := r.Header.Get("Origin")
+	if origin != "" && support.ArrayContains(config.HTTPCSRFTrustedOrigins, origin) {
+		return true
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "":
+		// Fallback to the "Origin" check for the older browsers.
+	case "same-origin", "none":
+		return true
+	default:
+		return false
+	}
+
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
 func mdwCSRFHandler(c *Context, config *support.Config, logger *support.Logger) {
 	if c.IsAPIOnly() {
 		c.Set(mdwCSRFSkipCheckCtxKey.String(), true)
@@ -312,10 +312,87 @@ func (s *mdwCSRFSuite) TestCSRFMiddleware() {
 	c.Request = &http.Request{
 		Header: map[string][]string{},
 	}
-	mdwCSRF(s.config, s.logger)(c)
+	mdwCSRF(s.config, s.logger, NewServer(s.asset, s.config, s.logger))(c)
 	s.NotNil(mdwCSRFSecureCookie)
 }
 
+func (s *mdwCSRFSuite) TestOriginStrategy() {
+	s.config.HTTPCSRFTrustedOrigins = []string{"https://app.example.com"}
+
+	tt := []struct {
+		method string
+		header map[string]string
+		status int
+	}{
+		{"GET", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusOK},
+		{"POST", map[string]string{}, http.StatusOK},
+		{"POST", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"POST", map[string]string{"Sec-Fetch-Site": "none"}, http.StatusOK},
+		{"POST", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"POST", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"POST", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://app.example.com"}, http.StatusOK},
+		{"POST", map[string]string{"Origin": "http://localhost"}, http.StatusOK},
+		{"POST", map[string]string{"Origin": "http://evil.com"}, http.StatusForbidden},
+		{"POST", map[string]string{"Sec-Fetch-Site": "cross-site", "X-API-Only": "1"}, http.StatusOK},
+	}
+
+	for _, t := range tt {
+		recorder := httptest.NewRecorder()
+		c, _ := NewTestContext(recorder)
+		c.Request = &http.Request{
+			Header: map[string][]string{},
+			Host:   "localhost",
+			Method: t.method,
+		}
+
+		for key, val := range t.header {
+			c.Request.Header.Set(key, val)
+		}
+
+		mdwCSRFOriginHandler(c, s.config, s.logger)
+		s.Equal(t.status, c.Writer.Status())
+
+		if t.status == http.StatusForbidden {
+			s.Equal(errCSRFCrossOrigin, c.Errors.Last().Err)
+		}
+	}
+}
+
+func (s *mdwCSRFSuite) TestStrategyPerRouteGroup() {
+	s.config.HTTPCSRFSecret = []byte("481e5d98a31585148b8b1dfb6a3c0465")
+	server := NewServer(s.asset, s.config, s.logger)
+	server.Use(mdwCSRF(s.config, s.logger, server))
+	server.SetCSRFStrategy("/api", CSRFStrategyOrigin)
+	server.SetCSRFStrategy("/api/legacy", CSRFStrategyToken)
+	server.POST("/api/users", func(c *Context) { c.String(http.StatusOK, "") })
+	server.POST("/api/legacy/users", func(c *Context) { c.String(http.StatusOK, "") })
+	server.POST("/users", func(c *Context) { c.String(http.StatusOK, "") })
+	server.POST("/apiv2/users", func(c *Context) { c.String(http.StatusOK, "") })
+	server.POST("/api-internal/users", func(c *Context) { c.String(http.StatusOK, "") })
+
+	w := server.TestHTTPRequest("POST", "/api/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	w = server.TestHTTPRequest("POST", "/apiv2/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = server.TestHTTPRequest("POST", "/api-internal/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = server.TestHTTPRequest("POST", "/api/users", H{"Sec-Fetch-Site": "cross-site"}, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = server.TestHTTPRequest("POST", "/api/legacy/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = server.TestHTTPRequest("POST", "/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	s.config.HTTPCSRFStrategy = "origin"
+	w = server.TestHTTPRequest("POST", "/users", H{"Sec-Fetch-Site": "same-origin"}, nil)
+	s.Equal(http.StatusOK, w.Code)
+}
+
 func TestMdwCSRFSuite(t *testing.T) {
 	test.Run(t, new(mdwCSRFSuite))
 }
@@ -16,6 +16,14 @@ func mdwHealthCheck(endpoint string, server *Server) HandlerFunc {
 	return func(c *Context) {
 		r := c.Request
 		if r.Method == "GET" && strings.EqualFold(r.URL.Path, endpoint) {
+			// Keep the load balancer from routing the traffic until the warmup
+			// hooks are done.
+			if !server.IsWarmedUp() {
+				c.String(http.StatusServiceUnavailable, "")
+				c.Abort()
+				return
+			}
+
 			c.String(http.StatusOK, "")
 			c.Abort()
 			return
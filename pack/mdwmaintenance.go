@@ -0,0 +1,21 @@
+package pack
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func mdwMaintenance(server *Server) HandlerFunc {
+	return func(c *Context) {
+		if server.IsMaintenance() {
+			if retryAfter := server.Config().HTTPMaintenanceRetryAfter; retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			}
+
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+)
+
+type mdwMaintenanceSuite struct {
+	test.Suite
+	asset     *support.Asset
+	config    *support.Config
+	lifecycle *support.Lifecycle
+	logger    *support.Logger
+	buffer    *bytes.Buffer
+	writer    *bufio.Writer
+	server    *Server
+}
+
+func (s *mdwMaintenanceSuite) SetupTest() {
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	s.logger, s.buffer, s.writer = support.NewTestLogger()
+	s.asset = support.NewAsset(nil, "")
+	s.config = support.NewConfig(s.asset, s.logger)
+	s.lifecycle = support.NewLifecycle(s.config, s.logger)
+	s.server = NewServer(s.asset, s.config, s.logger)
+	s.server.lifecycle = s.lifecycle
+	s.server.Use(mdwHealthCheck(s.config.HTTPHealthCheckPath, s.server))
+	s.server.Use(mdwMaintenance(s.server))
+	s.server.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+}
+
+func (s *mdwMaintenanceSuite) TearDownTest() {
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *mdwMaintenanceSuite) TestMaintenanceMode() {
+	events := []support.LifecycleEvent{}
+	listener := func(payload *support.LifecyclePayload) {
+		events = append(events, payload.Event)
+	}
+	s.lifecycle.On(support.LifecycleEventMaintenanceEnter, listener)
+	s.lifecycle.On(support.LifecycleEventMaintenanceExit, listener)
+
+	w := s.server.TestHTTPRequest("GET", "/ping", nil, nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	s.server.EnterMaintenance()
+	s.server.EnterMaintenance()
+	s.Equal(true, s.server.IsMaintenance())
+
+	w = s.server.TestHTTPRequest("GET", "/ping", nil, nil)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+	s.Equal("120", w.Header().Get("Retry-After"))
+
+	s.config.HTTPMaintenanceRetryAfter = 30 * time.Second
+	w = s.server.TestHTTPRequest("GET", "/ping", nil, nil)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+	s.Equal("30", w.Header().Get("Retry-After"))
+
+	s.config.HTTPMaintenanceRetryAfter = 0
+	w = s.server.TestHTTPRequest("GET", "/ping", nil, nil)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+	s.Equal("", w.Header().Get("Retry-After"))
+
+	w = s.server.TestHTTPRequest("GET", s.config.HTTPHealthCheckPath, nil, nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	s.server.ExitMaintenance()
+	s.server.ExitMaintenance()
+	s.Equal(false, s.server.IsMaintenance())
+
+	w = s.server.TestHTTPRequest("GET", "/ping", nil, nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal([]support.LifecycleEvent{support.LifecycleEventMaintenanceEnter, support.LifecycleEventMaintenanceExit}, events)
+}
+
+func TestMdwMaintenanceSuite(t *testing.T) {
+	test.Run(t, new(mdwMaintenanceSuite))
+}
@@ -6,12 +6,17 @@ import (
 )
 
 var (
-	xForwardedFor = http.CanonicalHeaderKey("x-forwarded-for")
-	xRealIP       = http.CanonicalHeaderKey("x-real-ip")
+	mdwRealIPPeerAddrCtxKey = ContextKey("mdwRealIPPeerAddr")
+	xForwardedFor           = http.CanonicalHeaderKey("x-forwarded-for")
+	xRealIP                 = http.CanonicalHeaderKey("x-real-ip")
 )
 
 func mdwRealIP() HandlerFunc {
 	return func(c *Context) {
+		// Keep the socket peer address for the checks that can't trust the
+		// request headers which are set by the client.
+		c.Set(mdwRealIPPeerAddrCtxKey.String(), c.Request.RemoteAddr)
+
 		if rip := realIP(c.Request); rip != "" {
 			c.Request.RemoteAddr = rip
 		}
@@ -0,0 +1,118 @@
+package pack
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/appist/appy/support"
+)
+
+type (
+	// RequestLimits defines the request header count/size and URL length limits
+	// that the HTTP server enforces. The zero value of each field means no limit
+	// for the defaults, but inherits the default for the route overrides which
+	// use -1 to mean no limit instead.
+	RequestLimits struct {
+		// MaxHeaderCount indicates the maximum number of request headers.
+		MaxHeaderCount int
+
+		// MaxHeaderSize indicates the maximum number of bytes of a single request
+		// header's key and values.
+		MaxHeaderSize int
+
+		// MaxURLLength indicates the maximum number of bytes of the request URI.
+		MaxURLLength int
+	}
+
+	// RequestLimitsStats contains the number of requests that are rejected due
+	// to exceeding the request limits.
+	RequestLimitsStats struct {
+		// HeaderCountExceeded indicates how many requests are rejected with 431
+		// due to having too many headers.
+		HeaderCountExceeded uint64
+
+		// HeaderSizeExceeded indicates how many requests are rejected with 431
+		// due to having a header that is too large.
+		HeaderSizeExceeded uint64
+
+		// URLLengthExceeded indicates how many requests are rejected with 414 due
+		// to having a URL that is too long.
+		URLLengthExceeded uint64
+	}
+)
+
+func newRequestLimits(config *support.Config) RequestLimits {
+	return RequestLimits{
+		MaxHeaderCount: config.HTTPMaxHeaderCount,
+		MaxHeaderSize:  config.HTTPMaxHeaderSize,
+		MaxURLLength:   config.HTTPMaxURLLength,
+	}
+}
+
+// inherit returns the limits with the zero fields taken from the defaults.
+func (l RequestLimits) inherit(defaults RequestLimits) RequestLimits {
+	if l.MaxHeaderCount == 0 {
+		l.MaxHeaderCount = defaults.MaxHeaderCount
+	}
+
+	if l.MaxHeaderSize == 0 {
+		l.MaxHeaderSize = defaults.MaxHeaderSize
+	}
+
+	if l.MaxURLLength == 0 {
+		l.MaxURLLength = defaults.MaxURLLength
+	}
+
+	return l
+}
+
+func mdwReqLimits(server *Server, logger *support.Logger) HandlerFunc {
+	return func(c *Context) {
+		limits := server.reqLimits
+		if routeLimits, exists := server.routeReqLimits[c.Request.Method+" "+c.FullPath()]; exists {
+			limits = routeLimits.inherit(limits)
+		}
+
+		r := c.Request
+		uri := r.RequestURI
+		if uri == "" {
+			uri = r.URL.RequestURI()
+		}
+
+		// Avoid logging the URL as it could be used to flood the logging pipeline.
+		if limits.MaxURLLength > 0 && len(uri) > limits.MaxURLLength {
+			atomic.AddUint64(&server.reqLimitsStats.URLLengthExceeded, 1)
+			logger.Warnf("[HTTP] %s %s rejected with %d: URL length %dB exceeds %dB", c.RequestID(), r.Method,
+				http.StatusRequestURITooLong, len(uri), limits.MaxURLLength)
+			c.AbortWithStatus(http.StatusRequestURITooLong)
+			return
+		}
+
+		if limits.MaxHeaderCount > 0 && len(r.Header) > limits.MaxHeaderCount {
+			atomic.AddUint64(&server.reqLimitsStats.HeaderCountExceeded, 1)
+			logger.Warnf("[HTTP] %s %s %s rejected with %d: header count %d exceeds %d", c.RequestID(), r.Method,
+				r.URL.Path, http.StatusRequestHeaderFieldsTooLarge, len(r.Header), limits.MaxHeaderCount)
+			c.AbortWithStatus(http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+
+		if limits.MaxHeaderSize > 0 {
+			for key, values := range r.Header {
+				size := len(key)
+				for _, value := range values {
+					size += len(value)
+				}
+
+				if size > limits.MaxHeaderSize {
+					atomic.AddUint64(&server.reqLimitsStats.HeaderSizeExceeded, 1)
+					logger.Warnf("[HTTP] %s %s %s rejected with %d: header '%s' size %dB exceeds %dB", c.RequestID(), r.Method,
+						r.URL.Path, http.StatusRequestHeaderFieldsTooLarge, key, size, limits.MaxHeaderSize)
+					c.AbortWithStatus(http.StatusRequestHeaderFieldsTooLarge)
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+)
+
+type mdwReqLimitsSuite struct {
+	test.Suite
+	asset  *support.Asset
+	config *support.Config
+	logger *support.Logger
+	buffer *bytes.Buffer
+	writer *bufio.Writer
+	server *Server
+}
+
+func (s *mdwReqLimitsSuite) SetupTest() {
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	s.logger, s.buffer, s.writer = support.NewTestLogger()
+	s.asset = support.NewAsset(nil, "")
+	s.config = support.NewConfig(s.asset, s.logger)
+	s.config.HTTPMaxHeaderCount = 3
+	s.config.HTTPMaxHeaderSize = 32
+	s.config.HTTPMaxURLLength = 32
+	s.server = NewServer(s.asset, s.config, s.logger)
+	s.server.Use(mdwReqLimits(s.server, s.logger))
+	s.server.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	s.server.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+}
+
+func (s *mdwReqLimitsSuite) TearDownTest() {
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *mdwReqLimitsSuite) TestRequestWithinLimits() {
+	w := s.server.TestHTTPRequest("GET", "/ping", H{"X-Foo": "bar"}, nil)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("pong", w.Body.String())
+	s.Equal(RequestLimitsStats{}, s.server.RequestLimitsStats())
+}
+
+func (s *mdwReqLimitsSuite) TestURLTooLong() {
+	w := s.server.TestHTTPRequest("GET", "/ping?q="+strings.Repeat("a", 32), nil, nil)
+	s.writer.Flush()
+
+	s.Equal(http.StatusRequestURITooLong, w.Code)
+	s.Equal(uint64(1), s.server.RequestLimitsStats().URLLengthExceeded)
+	s.Contains(s.buffer.String(), "rejected with 414")
+	s.NotContains(s.buffer.String(), strings.Repeat("a", 32))
+}
+
+func (s *mdwReqLimitsSuite) TestTooManyHeaders() {
+	header := H{}
+	for i := 0; i < 4; i++ {
+		header[fmt.Sprintf("X-Foo-%d", i)] = "bar"
+	}
+
+	w := s.server.TestHTTPRequest("GET", "/ping", header, nil)
+
+	s.Equal(http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	s.Equal(uint64(1), s.server.RequestLimitsStats().HeaderCountExceeded)
+}
+
+func (s *mdwReqLimitsSuite) TestHeaderTooLarge() {
+	w := s.server.TestHTTPRequest("GET", "/ping", H{"X-Foo": strings.Repeat("a", 32)}, nil)
+
+	s.Equal(http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	s.Equal(uint64(1), s.server.RequestLimitsStats().HeaderSizeExceeded)
+}
+
+func (s *mdwReqLimitsSuite) TestRouteRequestLimits() {
+	s.server.SetRouteRequestLimits("GET", "/users/:id", RequestLimits{MaxURLLength: 64})
+
+	w := s.server.TestHTTPRequest("GET", "/users/"+strings.Repeat("1", 40), nil, nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	w = s.server.TestHTTPRequest("GET", "/users/1", H{"X-Foo": strings.Repeat("a", 32)}, nil)
+	s.Equal(http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	s.Equal(uint64(1), s.server.RequestLimitsStats().HeaderSizeExceeded)
+
+	s.server.SetRouteRequestLimits("GET", "/users/:id", RequestLimits{MaxHeaderSize: -1, MaxURLLength: 64})
+	w = s.server.TestHTTPRequest("GET", "/users/1", H{"X-Foo": strings.Repeat("a", 32)}, nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	w = s.server.TestHTTPRequest("GET", "/users/"+strings.Repeat("1", 64), nil, nil)
+	s.Equal(http.StatusRequestURITooLong, w.Code)
+
+	w = s.server.TestHTTPRequest("GET", "/ping?q="+strings.Repeat("a", 32), nil, nil)
+	s.Equal(http.StatusRequestURITooLong, w.Code)
+	s.Equal(uint64(2), s.server.RequestLimitsStats().URLLengthExceeded)
+}
+
+func TestMdwReqLimitsSuite(t *testing.T) {
+	test.Run(t, new(mdwReqLimitsSuite))
+}
@@ -0,0 +1,147 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/appist/appy/support"
+	"github.com/gin-gonic/gin"
+)
+
+// RouteSchema keeps the JSON schemas of the route's request/response body
+// which are derived from the handler's input/output structs.
+type RouteSchema struct {
+	Request  *support.JSONSchema
+	Response *support.JSONSchema
+}
+
+type schemaResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *schemaResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *schemaResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+var (
+	errSchemaBodyTooLarge = errors.New("the request body is too large")
+)
+
+func mdwSchema(config *support.Config, logger *support.Logger, server *Server) HandlerFunc {
+	return func(c *Context) {
+		schema, ok := server.routeSchemas[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if schema.Request != nil && hasSchemaRequestBody(c.Request) {
+			errs, err := validateSchemaRequest(c.Writer, c.Request, schema.Request, config.HTTPSchemaMaxBodySize)
+			if err == errSchemaBodyTooLarge {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, H{
+					"errors": []support.JSONSchemaError{{Message: err.Error()}},
+				})
+				return
+			}
+
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, H{
+					"errors": []support.JSONSchemaError{{Message: err.Error()}},
+				})
+				return
+			}
+
+			if len(errs) > 0 {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, H{"errors": errs})
+				return
+			}
+		}
+
+		if schema.Response == nil || !config.HTTPSchemaValidateResponse {
+			c.Next()
+			return
+		}
+
+		writer := &schemaResponseWriter{c.Writer, &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+
+		if c.Writer.Status() < 200 || c.Writer.Status() > 299 || !strings.Contains(c.Writer.Header().Get("Content-Type"), "json") {
+			return
+		}
+
+		var body interface{}
+		if err := json.Unmarshal(writer.body.Bytes(), &body); err != nil {
+			logger.Warnf("[SCHEMA] response of '%s %s' isn't a valid JSON: %s", c.Request.Method, c.FullPath(), err)
+			return
+		}
+
+		for _, err := range schema.Response.Validate(body) {
+			logger.Warnf("[SCHEMA] response of '%s %s' doesn't match the schema: '%s' %s", c.Request.Method, c.FullPath(), err.Path, err.Message)
+		}
+	}
+}
+
+// isSchemaJSONRequest checks if the request is supposed to have the JSON body
+// which excludes the form/multipart requests that are bound differently.
+// hasSchemaRequestBody checks if the request has a body to validate which
+// doesn't depend on the "Content-Type" header as the handler's ShouldBindJSON
+// decodes the body as JSON regardless.
+func hasSchemaRequestBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPatch, http.MethodPost, http.MethodPut:
+		return true
+	}
+
+	return r.ContentLength > 0
+}
+
+// validateSchemaRequest validates the JSON request body against the schema
+// and restores the request body so that it can still be bound by the handler.
+// The request body is only read up to maxBodySize bytes to avoid buffering
+// an arbitrarily large body in memory.
+func validateSchemaRequest(w http.ResponseWriter, r *http.Request, schema *support.JSONSchema, maxBodySize int64) ([]support.JSONSchemaError, error) {
+	data := []byte{}
+
+	if r.Body != nil {
+		var err error
+
+		if maxBodySize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+
+		data, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			if maxBodySize > 0 && int64(len(data)) >= maxBodySize {
+				return nil, errSchemaBodyTooLarge
+			}
+
+			return nil, err
+		}
+
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("null")
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+
+	return schema.Validate(body), nil
+}
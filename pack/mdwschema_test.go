@@ -0,0 +1,131 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+)
+
+type (
+	mdwSchemaSuite struct {
+		test.Suite
+		asset  *support.Asset
+		config *support.Config
+		logger *support.Logger
+		buffer *bytes.Buffer
+		writer *bufio.Writer
+		server *Server
+	}
+
+	mdwSchemaInput struct {
+		Email string `json:"email" binding:"required,email"`
+		Name  string `json:"name" binding:"required"`
+	}
+
+	mdwSchemaOutput struct {
+		ID   int64  `json:"id" binding:"required"`
+		Name string `json:"name" binding:"required"`
+	}
+)
+
+func (s *mdwSchemaSuite) SetupTest() {
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	s.logger, s.buffer, s.writer = support.NewTestLogger()
+	s.asset = support.NewAsset(nil, "")
+	s.config = support.NewConfig(s.asset, s.logger)
+	s.server = NewServer(s.asset, s.config, s.logger)
+	s.server.Use(mdwSchema(s.config, s.logger, s.server))
+	s.server.POST("/users", func(c *Context) {
+		var input mdwSchemaInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		c.JSON(http.StatusCreated, H{"name": input.Name})
+	})
+	s.server.SetRouteSchema("POST", "/users", mdwSchemaInput{}, mdwSchemaOutput{})
+}
+
+func (s *mdwSchemaSuite) TearDownTest() {
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *mdwSchemaSuite) TestValidRequest() {
+	w := s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(`{"email":"john@example.com","name":"John"}`))
+
+	s.Equal(http.StatusCreated, w.Code)
+	s.Equal(`{"name":"John"}`, w.Body.String())
+}
+
+func (s *mdwSchemaSuite) TestInvalidRequest() {
+	w := s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(`{"email":"john"}`))
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Equal(`{"errors":[{"path":"name","message":"is required"},{"path":"email","message":"must be a valid email"}]}`, w.Body.String())
+
+	w = s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(`{"email":`))
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.Equal(`{"errors":[{"path":"","message":"unexpected end of JSON input"}]}`, w.Body.String())
+
+	w = s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, nil)
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Equal(`{"errors":[{"path":"","message":"must be object"}]}`, w.Body.String())
+}
+
+func (s *mdwSchemaSuite) TestRequestBodyTooLarge() {
+	s.config.HTTPSchemaMaxBodySize = 64
+	body := `{"email":"john@example.com","name":"` + strings.Repeat("a", 64) + `"}`
+
+	w := s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(body))
+	s.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	s.Equal(`{"errors":[{"path":"","message":"the request body is too large"}]}`, w.Body.String())
+
+	s.config.HTTPSchemaMaxBodySize = 0
+	w = s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(body))
+	s.Equal(http.StatusCreated, w.Code)
+}
+
+func (s *mdwSchemaSuite) TestNonJSONContentTypeIsValidated() {
+	w := s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "text/plain"}, strings.NewReader(`{"email":"john"}`))
+
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Contains(w.Body.String(), `{"path":"email","message":"must be a valid email"}`)
+
+	w = s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/x-www-form-urlencoded"}, strings.NewReader("name=John"))
+
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.Contains(w.Body.String(), `"errors"`)
+
+	w = s.server.TestHTTPRequest("POST", "/users", nil, strings.NewReader(`{"email":"john@example.com","name":"John"}`))
+
+	s.Equal(http.StatusCreated, w.Code)
+}
+
+func (s *mdwSchemaSuite) TestResponseValidation() {
+	w := s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(`{"email":"john@example.com","name":"John"}`))
+	s.Equal(http.StatusCreated, w.Code)
+	s.writer.Flush()
+	s.NotContains(s.buffer.String(), "[SCHEMA]")
+
+	s.config.HTTPSchemaValidateResponse = true
+	w = s.server.TestHTTPRequest("POST", "/users", H{"Content-Type": "application/json"}, strings.NewReader(`{"email":"john@example.com","name":"John"}`))
+	s.Equal(http.StatusCreated, w.Code)
+	s.Equal(`{"name":"John"}`, w.Body.String())
+	s.writer.Flush()
+	s.Contains(s.buffer.String(), "[SCHEMA] response of 'POST /users' doesn't match the schema: 'id' is required")
+}
+
+func TestMdwSchemaSuite(t *testing.T) {
+	test.Run(t, new(mdwSchemaSuite))
+}
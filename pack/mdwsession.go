@@ -14,11 +14,19 @@ import (
 
 var (
 	mdwSessionCtxKey = ContextKey("sessionManager")
+
+	// ErrUserSessionsNotSupported indicates the session store is unable to
+	// list or revoke the user's sessions, i.e. "cookie" session provider.
+	ErrUserSessionsNotSupported = sessionstore.ErrUserSessionsNotSupported
 )
 
 // SessionOptions defines the session cookie's configuration.
 type SessionOptions = ginsessions.Options
 
+// SessionInfo contains the information of a user's active session, i.e. the
+// device, IP and last seen time.
+type SessionInfo = sessionstore.SessionInfo
+
 // Sessioner stores the values and optional configuration for a session.
 type Sessioner interface {
 	// AddFlash adds a flash message to the session.
@@ -40,6 +48,9 @@ type Sessioner interface {
 	// Get returns the session value associated to the given key.
 	Get(key interface{}) interface{}
 
+	// ID returns the session ID, not available for CookieStore.
+	ID() string
+
 	// Key returns the session key.
 	Key() string
 
@@ -58,6 +69,23 @@ type Sessioner interface {
 	// SetKeyPrefix sets the key prefix for the session, not available for CookieStore.
 	SetKeyPrefix(p string)
 
+	// SetUserID associates the session with the user so that it can be listed
+	// and revoked, not available for CookieStore.
+	SetUserID(userID string)
+
+	// UserID returns the user ID that the session is associated with.
+	UserID() string
+
+	// UserSessions returns the user's active sessions, not available for CookieStore.
+	UserSessions(userID string) ([]SessionInfo, error)
+
+	// RevokeUserSession revokes the user's specific session, not available for CookieStore.
+	RevokeUserSession(userID, sessionID string) error
+
+	// RevokeUserSessions revokes all the user's sessions which is useful for
+	// "sign out everywhere", not available for CookieStore.
+	RevokeUserSessions(userID string) error
+
 	// Values returns all values in the session.
 	Values() map[interface{}]interface{}
 }
@@ -69,10 +97,22 @@ func mdwSession(config *support.Config) HandlerFunc {
 			panic(err)
 		}
 
-		s := &Session{config.HTTPSessionCookieName, c.Request, sessionStore, nil, false, c.Writer}
+		s := &Session{config.HTTPSessionCookieName, c.Request, sessionStore, nil, false, false, c.Writer}
 		c.Set(mdwSessionCtxKey.String(), s)
 		defer gorcontext.Clear(c.Request)
 		c.Next()
+
+		// Refresh the user's session last seen time if it is loaded in the
+		// request and not saved which already tracks it.
+		if s.session != nil && !s.session.IsNew && !s.saved {
+			if err := sessionStore.Track(c.Request, s.session); err != nil {
+				c.Error(err)
+
+				if logger := c.Logger(); logger != nil {
+					logger.Errorf("[SESSION] unable to track the session: %s", err)
+				}
+			}
+		}
 	}
 }
 
@@ -88,6 +128,20 @@ type SessionStore interface {
 
 	// SetKeyPrefix sets the prefix for the store key, not available for CookieStore.
 	SetKeyPrefix(p string)
+
+	// Track records the session's device, IP and last seen time if it is
+	// associated with a user and its data still exists, which is throttled to
+	// once a minute for the same device and IP, not available for CookieStore.
+	Track(r *http.Request, session *gorsessions.Session) error
+
+	// UserSessions returns the user's active sessions, not available for CookieStore.
+	UserSessions(userID string) ([]SessionInfo, error)
+
+	// RevokeUserSession revokes the user's specific session, not available for CookieStore.
+	RevokeUserSession(userID, sessionID string) error
+
+	// RevokeUserSessions revokes all the user's sessions, not available for CookieStore.
+	RevokeUserSessions(userID string) error
 }
 
 func newSessionStore(config *support.Config) (SessionStore, error) {
@@ -111,6 +165,13 @@ func newSessionStore(config *support.Config) (SessionStore, error) {
 			PoolSize:           config.HTTPSessionRedisPoolSize,
 			PoolTimeout:        config.HTTPSessionRedisPoolTimeout,
 		}, config.HTTPSessionSecrets...)
+	case "kv":
+		var kvStore *support.KVStore
+
+		kvStore, err = support.OpenKVStore(config.KVStorePath)
+		if err == nil {
+			sessionStore = sessionstore.NewKVStore(kvStore, config.HTTPSessionSecrets...)
+		}
 	default:
 		err = fmt.Errorf("session provider '%s' is not supported", provider)
 	}
@@ -136,6 +197,7 @@ type Session struct {
 	store   SessionStore
 	session *gorsessions.Session
 	written bool
+	saved   bool
 	writer  http.ResponseWriter
 }
 
@@ -194,6 +256,7 @@ func (s *Session) Save() error {
 	err := s.Session().Save(s.request, s.writer)
 	if err == nil {
 		s.written = false
+		s.saved = true
 	}
 
 	return err
@@ -213,6 +276,15 @@ func (s *Session) Session() *gorsessions.Session {
 	return s.session
 }
 
+// ID returns the session ID, not available for CookieStore.
+func (s *Session) ID() string {
+	if s.Session() == nil {
+		return ""
+	}
+
+	return s.Session().ID
+}
+
 // Key returns the session key.
 func (s *Session) Key() string {
 	return s.KeyPrefix() + s.session.ID
@@ -234,6 +306,39 @@ func (s *Session) Set(key interface{}, val interface{}) {
 	s.written = true
 }
 
+// SetUserID associates the session with the user so that it can be listed
+// and revoked, not available for CookieStore.
+func (s *Session) SetUserID(userID string) {
+	s.Set(sessionstore.UserIDKey, userID)
+}
+
+// UserID returns the user ID that the session is associated with.
+func (s *Session) UserID() string {
+	if s.Session() == nil {
+		return ""
+	}
+
+	userID, _ := s.Get(sessionstore.UserIDKey).(string)
+
+	return userID
+}
+
+// UserSessions returns the user's active sessions, not available for CookieStore.
+func (s *Session) UserSessions(userID string) ([]SessionInfo, error) {
+	return s.store.UserSessions(userID)
+}
+
+// RevokeUserSession revokes the user's specific session, not available for CookieStore.
+func (s *Session) RevokeUserSession(userID, sessionID string) error {
+	return s.store.RevokeUserSession(userID, sessionID)
+}
+
+// RevokeUserSessions revokes all the user's sessions which is useful for
+// "sign out everywhere", not available for CookieStore.
+func (s *Session) RevokeUserSessions(userID string) error {
+	return s.store.RevokeUserSessions(userID)
+}
+
 // Values returns all values in the session.
 func (s *Session) Values() map[interface{}]interface{} {
 	if s.Session() == nil {
@@ -2,10 +2,13 @@ package pack
 
 import (
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/appist/appy/support"
 	"github.com/appist/appy/test"
@@ -103,6 +106,21 @@ func (s *mdwSessionSuite) TestSessionRedisStore() {
 	testOps(s, session)
 }
 
+func (s *mdwSessionSuite) TestSessionKVStore() {
+	dir, err := ioutil.TempDir("", "session")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	c, _ := NewTestContext(s.recorder)
+	c.Request = &http.Request{}
+	s.config.HTTPSessionProvider = "kv"
+	s.config.KVStorePath = filepath.Join(dir, "appy.db")
+	mdwSession(s.config)(c)
+	session := c.Session()
+
+	testOps(s, session)
+}
+
 func (s *mdwSessionSuite) TestSessionRedisStoreWrongAddr() {
 	ctx, _ := NewTestContext(s.recorder)
 	ctx.Request = &http.Request{}
@@ -142,6 +160,182 @@ func (s *mdwSessionSuite) TestCustomSessionKey() {
 	s.Contains(session.Key(), "mysession:")
 }
 
+func (s *mdwSessionSuite) TestUserSessionsCookieStore() {
+	c, _ := NewTestContext(s.recorder)
+	c.Request = &http.Request{}
+	s.config.HTTPSessionProvider = "cookie"
+	mdwSession(s.config)(c)
+
+	session := c.Session()
+	session.SetUserID("1")
+	s.Nil(session.Save())
+	s.Equal("1", session.UserID())
+
+	_, err := session.UserSessions("1")
+	s.Equal(ErrUserSessionsNotSupported, err)
+	s.Equal(ErrUserSessionsNotSupported, session.RevokeUserSession("1", session.ID()))
+	s.Equal(ErrUserSessionsNotSupported, session.RevokeUserSessions("1"))
+}
+
+func (s *mdwSessionSuite) TestUserSessionsRedisStore() {
+	s.config.HTTPSessionProvider = "redis"
+	testUserSessions(s)
+	testUserSessionsTracking(s)
+	testUserSessionsThrottling(s)
+}
+
+func (s *mdwSessionSuite) TestUserSessionsKVStore() {
+	dir, err := ioutil.TempDir("", "session")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	s.config.HTTPSessionProvider = "kv"
+	s.config.KVStorePath = filepath.Join(dir, "appy.db")
+	testUserSessions(s)
+	testUserSessionsTracking(s)
+	testUserSessionsThrottling(s)
+}
+
+func testUserSessions(s *mdwSessionSuite) {
+	sessions := []Sessioner{}
+
+	for _, userAgent := range []string{"Chrome", "Firefox"} {
+		c, _ := NewTestContext(httptest.NewRecorder())
+		c.Request = &http.Request{
+			Header:     http.Header{"User-Agent": []string{userAgent}},
+			RemoteAddr: "10.0.0.1:1234",
+		}
+		mdwSession(s.config)(c)
+
+		session := c.Session()
+		session.SetUserID("user-sessions")
+		s.Nil(session.Save())
+		sessions = append(sessions, session)
+	}
+
+	infos, err := sessions[0].UserSessions("user-sessions")
+	s.Nil(err)
+	s.Equal(2, len(infos))
+
+	for _, info := range infos {
+		s.Equal("user-sessions", info.UserID)
+		s.Equal("10.0.0.1", info.IP)
+		s.Contains([]string{"Chrome", "Firefox"}, info.UserAgent)
+		s.Contains([]string{sessions[0].ID(), sessions[1].ID()}, info.ID)
+	}
+
+	s.Nil(sessions[0].RevokeUserSession("someone-else", sessions[1].ID()))
+	infos, err = sessions[0].UserSessions("user-sessions")
+	s.Nil(err)
+	s.Equal(2, len(infos))
+
+	s.Nil(sessions[0].RevokeUserSession("user-sessions", sessions[1].ID()))
+	infos, err = sessions[0].UserSessions("user-sessions")
+	s.Nil(err)
+	s.Equal(1, len(infos))
+	s.Equal(sessions[0].ID(), infos[0].ID)
+
+	// The user ID that is another user ID's prefix doesn't see or revoke the
+	// other user's sessions.
+	for _, userID := range []string{"user", "user-sessions:1"} {
+		c, _ := NewTestContext(httptest.NewRecorder())
+		c.Request = &http.Request{Header: http.Header{}}
+		mdwSession(s.config)(c)
+
+		session := c.Session()
+		session.SetUserID(userID)
+		s.Nil(session.Save())
+	}
+
+	s.Nil(sessions[0].RevokeUserSessions("user-sessions"))
+	infos, err = sessions[0].UserSessions("user-sessions")
+	s.Nil(err)
+	s.Equal(0, len(infos))
+
+	for _, userID := range []string{"user", "user-sessions:1"} {
+		infos, err = sessions[0].UserSessions(userID)
+		s.Nil(err)
+		s.Equal(1, len(infos))
+		s.Equal(userID, infos[0].UserID)
+	}
+}
+
+func testUserSessionsTracking(s *mdwSessionSuite) {
+	c, _ := NewTestContext(httptest.NewRecorder())
+	c.Request = &http.Request{Header: http.Header{}}
+	mdwSession(s.config)(c)
+
+	session := c.Session().(*Session)
+	session.SetUserID("user-tracking")
+	s.Nil(session.Save())
+	s.Nil(session.RevokeUserSessions("user-tracking"))
+
+	// The session that is revoked or logged out in the request isn't tracked
+	// again after the request.
+	s.Nil(session.store.Track(session.request, session.Session()))
+	infos, err := session.UserSessions("user-tracking")
+	s.Nil(err)
+	s.Equal(0, len(infos))
+
+	session.Session().Options.MaxAge = -1
+	s.Nil(session.store.Track(session.request, session.Session()))
+	infos, err = session.UserSessions("user-tracking")
+	s.Nil(err)
+	s.Equal(0, len(infos))
+
+	// The session info doesn't outlive the session data which expires.
+	c, _ = NewTestContext(httptest.NewRecorder())
+	c.Request = &http.Request{Header: http.Header{}}
+	mdwSession(s.config)(c)
+
+	session = c.Session().(*Session)
+	session.Options(SessionOptions{MaxAge: 1})
+	session.SetUserID("user-tracking")
+	s.Nil(session.Save())
+
+	time.Sleep(600 * time.Millisecond)
+	s.Nil(session.store.Track(session.request, session.Session()))
+	infos, err = session.UserSessions("user-tracking")
+	s.Nil(err)
+	s.Equal(1, len(infos))
+
+	time.Sleep(600 * time.Millisecond)
+	infos, err = session.UserSessions("user-tracking")
+	s.Nil(err)
+	s.Equal(0, len(infos))
+}
+
+func testUserSessionsThrottling(s *mdwSessionSuite) {
+	// The last seen time is only refreshed once a minute unless the device or
+	// IP changes.
+	c, _ := NewTestContext(httptest.NewRecorder())
+	c.Request = &http.Request{Header: http.Header{}, RemoteAddr: "10.0.0.1:1234"}
+	mdwSession(s.config)(c)
+
+	session := c.Session().(*Session)
+	session.SetUserID("user-throttling")
+	s.Nil(session.Save())
+
+	infos, err := session.UserSessions("user-throttling")
+	s.Nil(err)
+	s.Equal(1, len(infos))
+	lastSeenAt := infos[0].LastSeenAt
+
+	time.Sleep(10 * time.Millisecond)
+	s.Nil(session.store.Track(session.request, session.Session()))
+	infos, err = session.UserSessions("user-throttling")
+	s.Nil(err)
+	s.Equal(lastSeenAt, infos[0].LastSeenAt)
+
+	session.request.RemoteAddr = "10.0.0.2:1234"
+	s.Nil(session.store.Track(session.request, session.Session()))
+	infos, err = session.UserSessions("user-throttling")
+	s.Nil(err)
+	s.Equal("10.0.0.2", infos[0].IP)
+	s.True(infos[0].LastSeenAt.After(lastSeenAt))
+	s.Nil(session.RevokeUserSessions("user-throttling"))
+}
+
 type fakeSessionStore struct {
 	keyPrefix string
 }
@@ -158,6 +352,10 @@ func (fss *fakeSessionStore) Options(SessionOptions) {}
 func (fss *fakeSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gorsessions.Session) error {
 	return nil
 }
+func (fss *fakeSessionStore) Track(r *http.Request, session *gorsessions.Session) error { return nil }
+func (fss *fakeSessionStore) UserSessions(userID string) ([]SessionInfo, error)         { return nil, nil }
+func (fss *fakeSessionStore) RevokeUserSession(userID, sessionID string) error          { return nil }
+func (fss *fakeSessionStore) RevokeUserSessions(userID string) error                    { return nil }
 
 func (s *mdwSessionSuite) TestSessionStoreUnableToReturnSession() {
 	c, _ := NewTestContext(s.recorder)
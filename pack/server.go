@@ -2,6 +2,7 @@ package pack
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"html/template"
 	"io"
@@ -12,6 +13,8 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	gqlHandler "github.com/99designs/gqlgen/graphql/handler"
@@ -30,17 +33,33 @@ import (
 type (
 	// Server processes the HTTP requests.
 	Server struct {
-		asset        *support.Asset
-		config       *support.Config
-		http         *http.Server
-		https        *http.Server
-		logger       *support.Logger
-		middleware   []HandlerFunc
-		mdwRoutes    []Route
-		router       *Router
-		spaResources []*spaResource
+		asset          *support.Asset
+		config         *support.Config
+		csrfStrategies map[string]CSRFStrategy
+		gqlSchemas     map[string]graphql.ExecutableSchema
+		http           *http.Server
+		https          *http.Server
+		lifecycle      *support.Lifecycle
+		logger         *support.Logger
+		maintenance    int32
+		middleware     []HandlerFunc
+		mdwRoutes      []Route
+		reqLimits      RequestLimits
+		reqLimitsStats *RequestLimitsStats
+		routeDocs      map[string]string
+		routeReqLimits map[string]RequestLimits
+		routeSchemas   map[string]*RouteSchema
+		router         *Router
+		spaResources   []*spaResource
+		warmedUp       int32
+		warmupHooks    []warmupHook
 	}
 
+	// WarmupHook primes the server, i.e. caches, templates or feature flags,
+	// before the health check reports that it is ready to receive the HTTP
+	// requests. The context is cancelled once the hook's timeout is reached.
+	WarmupHook func(ctx context.Context) error
+
 	spaResource struct {
 		fs         http.FileSystem
 		fileServer http.Handler
@@ -75,35 +94,47 @@ func NewServer(asset *support.Asset, config *support.Config, logger *support.Log
 	hss.ErrorLog = zap.NewStdLog(logger.Desugar())
 
 	return &Server{
-		asset:        asset,
-		config:       config,
-		http:         hs,
-		https:        hss,
-		logger:       logger,
-		middleware:   []HandlerFunc{},
-		mdwRoutes:    []Route{},
-		router:       router,
-		spaResources: []*spaResource{},
+		asset:          asset,
+		config:         config,
+		csrfStrategies: map[string]CSRFStrategy{},
+		gqlSchemas:     map[string]graphql.ExecutableSchema{},
+		http:           hs,
+		https:          hss,
+		logger:         logger,
+		middleware:     []HandlerFunc{},
+		mdwRoutes:      []Route{},
+		reqLimits:      newRequestLimits(config),
+		reqLimitsStats: &RequestLimitsStats{},
+		routeDocs:      map[string]string{},
+		routeReqLimits: map[string]RequestLimits{},
+		routeSchemas:   map[string]*RouteSchema{},
+		router:         router,
+		spaResources:   []*spaResource{},
+		warmupHooks:    []warmupHook{},
 	}
 }
 
 // NewAppServer initializes Server instance with built-in middleware.
-func NewAppServer(asset *support.Asset, config *support.Config, i18n *support.I18n, ml *mailer.Engine, logger *support.Logger, viewFuncs map[string]interface{}) *Server {
+func NewAppServer(asset *support.Asset, config *support.Config, i18n *support.I18n, ml *mailer.Engine, lifecycle *support.Lifecycle, logger *support.Logger, viewFuncs map[string]interface{}) *Server {
 	server := NewServer(asset, config, logger)
+	server.lifecycle = lifecycle
 	server.Use(mdwLogger(logger))
 	server.Use(mdwI18n(i18n))
 	server.Use(mdwMailer(ml, i18n, server))
 	server.Use(mdwViewEngine(asset, config, logger, viewFuncs))
 	server.Use(mdwRealIP())
 	server.Use(mdwReqID())
+	server.Use(mdwReqLimits(server, logger))
 	server.Use(mdwReqLogger(config, logger))
 	server.Use(mdwGzip(config))
 	server.Use(mdwHealthCheck(config.HTTPHealthCheckPath, server))
+	server.Use(mdwMaintenance(server))
 	server.Use(mdwPrerender(config, logger))
-	server.Use(mdwCSRF(config, logger))
+	server.Use(mdwCSRF(config, logger, server))
 	server.Use(mdwSecure(config))
 	server.Use(mdwAPIOnly())
 	server.Use(mdwSession(config))
+	server.Use(mdwSchema(config, logger, server))
 	server.Use(mdwRecovery(logger))
 
 	return server
@@ -119,6 +150,37 @@ func (s *Server) Config() *support.Config {
 	return s.config
 }
 
+// EnterMaintenance puts the server into the maintenance mode which responds
+// to all the HTTP requests except the health check with "503 Service
+// Unavailable" and emits the "app.maintenance.enter" lifecycle event. The
+// `serve` command also calls it upon SIGUSR1 except on Windows.
+func (s *Server) EnterMaintenance() {
+	if !atomic.CompareAndSwapInt32(&s.maintenance, 0, 1) {
+		return
+	}
+
+	s.logger.Info("* Entering the maintenance mode...")
+
+	if s.lifecycle != nil {
+		s.lifecycle.Emit(support.LifecycleEventMaintenanceEnter, nil)
+	}
+}
+
+// ExitMaintenance takes the server out of the maintenance mode and emits the
+// "app.maintenance.exit" lifecycle event. The `serve` command also calls it
+// upon SIGUSR2 except on Windows.
+func (s *Server) ExitMaintenance() {
+	if !atomic.CompareAndSwapInt32(&s.maintenance, 1, 0) {
+		return
+	}
+
+	s.logger.Info("* Exiting the maintenance mode...")
+
+	if s.lifecycle != nil {
+		s.lifecycle.Emit(support.LifecycleEventMaintenanceExit, nil)
+	}
+}
+
 // HTTP returns the HTTP server instance.
 func (s *Server) HTTP() *http.Server {
 	return s.http
@@ -170,6 +232,11 @@ func (s *Server) Info() []string {
 	return append(lines, fmt.Sprintf("* Listening on %s", host))
 }
 
+// IsMaintenance checks if the server is in the maintenance mode.
+func (s *Server) IsMaintenance() bool {
+	return atomic.LoadInt32(&s.maintenance) == 1
+}
+
 // IsSSLCertExisted checks if `./tmp/ssl` exists and contains the locally trusted SSL certificates.
 func (s *Server) IsSSLCertExisted() bool {
 	_, certErr := os.Stat(s.config.HTTPSSLCertPath + "/cert.pem")
@@ -182,6 +249,78 @@ func (s *Server) IsSSLCertExisted() bool {
 	return true
 }
 
+// IsWarmedUp checks if all the warmup hooks that are registered via OnWarmup
+// have been run.
+func (s *Server) IsWarmedUp() bool {
+	return len(s.warmupHooks) == 0 || atomic.LoadInt32(&s.warmedUp) == 1
+}
+
+// Lifecycle returns the application lifecycle which emits the lifecycle
+// events.
+func (s *Server) Lifecycle() *support.Lifecycle {
+	return s.lifecycle
+}
+
+// OnWarmup registers the hook to run in the registration order before the
+// health check flips healthy. If the timeout is 0, HTTP_WARMUP_TIMEOUT is
+// used instead.
+func (s *Server) OnWarmup(name string, timeout time.Duration, hook WarmupHook) {
+	if timeout <= 0 {
+		timeout = s.config.HTTPWarmupTimeout
+	}
+
+	s.warmupHooks = append(s.warmupHooks, warmupHook{name, timeout, hook})
+}
+
+// RequestLimits returns the default request limits which are configured via
+// HTTP_MAX_HEADER_COUNT, HTTP_MAX_HEADER_SIZE and HTTP_MAX_URL_LENGTH.
+func (s *Server) RequestLimits() RequestLimits {
+	return s.reqLimits
+}
+
+// RequestLimitsStats returns the number of requests that are rejected due to
+// exceeding the request limits since the server is initialized.
+func (s *Server) RequestLimitsStats() RequestLimitsStats {
+	return RequestLimitsStats{
+		HeaderCountExceeded: atomic.LoadUint64(&s.reqLimitsStats.HeaderCountExceeded),
+		HeaderSizeExceeded:  atomic.LoadUint64(&s.reqLimitsStats.HeaderSizeExceeded),
+		URLLengthExceeded:   atomic.LoadUint64(&s.reqLimitsStats.URLLengthExceeded),
+	}
+}
+
+// SetCSRFStrategy overrides HTTP_CSRF_STRATEGY for the routes under the path
+// prefix, i.e. the route group's base path, which only matches at the path
+// segment boundary, i.e. "/api" matches "/api/users" but not "/apiv2/users".
+// The longest matching prefix wins.
+func (s *Server) SetCSRFStrategy(prefix string, strategy CSRFStrategy) {
+	s.csrfStrategies[prefix] = strategy
+}
+
+// SetRouteDoc sets the route description that is shown in the docs.
+func (s *Server) SetRouteDoc(method, path, description string) {
+	s.routeDocs[method+" "+path] = description
+}
+
+// SetRouteRequestLimits overrides the default request limits for the route
+// that matches the method and path, i.e. SetRouteRequestLimits("POST",
+// "/users/:id", limits). The zero fields inherit the defaults and -1 means no
+// limit.
+func (s *Server) SetRouteRequestLimits(method, path string, limits RequestLimits) {
+	s.routeReqLimits[method+" "+path] = limits
+}
+
+// SetRouteSchema derives the JSON schemas from the route's request/response
+// body structs, i.e. SetRouteSchema("POST", "/users", CreateUserInput{},
+// User{}). The request body is validated against the schema before reaching
+// the handler and the schemas are included in the OpenAPI doc. Pass nil to
+// skip either of them.
+func (s *Server) SetRouteSchema(method, path string, request, response interface{}) {
+	s.routeSchemas[method+" "+path] = &RouteSchema{
+		Request:  support.NewJSONSchema(request),
+		Response: support.NewJSONSchema(response),
+	}
+}
+
 // Router returns the router instance.
 func (s *Server) Router() *Router {
 	return s.router
@@ -212,8 +351,63 @@ func (s *Server) ServeSPA(prefix string, fs http.FileSystem) {
 	s.router.Use(mdwSPA(s, prefix, fs))
 }
 
+// SetupDocs serves the documentation at HTTP_DOCS_PATH behind the basic
+// authentication with HTTP_DOCS_USERNAME/HTTP_DOCS_PASSWORD so that it is
+// only accessible by the internal consumers.
+func (s *Server) SetupDocs(docs *Docs) {
+	if s.config.HTTPDocsUsername == "" || s.config.HTTPDocsPassword == "" {
+		s.logger.Warnf("[DOCS] HTTP_DOCS_USERNAME and HTTP_DOCS_PASSWORD are required to serve the docs at '%s'", s.config.HTTPDocsPath)
+		return
+	}
+
+	// The relative link to the OpenAPI doc only works for the static site.
+	served := *docs
+	served.OpenAPIURL = s.config.HTTPDocsPath + "/" + docsOpenAPIFilename
+
+	content, err := served.Render()
+	if err != nil {
+		s.logger.Error(err)
+		return
+	}
+
+	s.GET(s.config.HTTPDocsPath, CSRFSkipCheck(), func(c *Context) {
+		if !s.isDocsAuthorized(c) {
+			return
+		}
+
+		c.Data(http.StatusOK, "text/html; charset=utf-8", content)
+	})
+
+	openAPI, err := docs.OpenAPI()
+	if err != nil {
+		s.logger.Error(err)
+		return
+	}
+
+	s.GET(served.OpenAPIURL, CSRFSkipCheck(), func(c *Context) {
+		if !s.isDocsAuthorized(c) {
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json; charset=utf-8", openAPI)
+	})
+
+	for _, gql := range docs.GraphQL {
+		sdl := []byte(gql.SDL)
+
+		s.GET(s.config.HTTPDocsPath+"/"+docsGQLFilename(gql.Path), CSRFSkipCheck(), func(c *Context) {
+			if !s.isDocsAuthorized(c) {
+				return
+			}
+
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", sdl)
+		})
+	}
+}
+
 // SetupGraphQL sets up the GraphQL stack.
 func (s *Server) SetupGraphQL(path string, es graphql.ExecutableSchema, exts []graphql.HandlerExtension) {
+	s.gqlSchemas[path] = es
 	gqlServer := gqlHandler.New(es)
 	gqlServer.AddTransport(transport.Websocket{
 		KeepAlivePingInterval: s.Config().GQLWebsocketKeepAliveDuration,
@@ -251,14 +445,24 @@ func (s *Server) SetupGraphQL(path string, es graphql.ExecutableSchema, exts []g
 		return err.(error)
 	})
 
-	gqlServer.Use(extension.Introspection{})
+	gqlServer.Use(gqlIntrospection{})
 
 	APQCacheSize := 100
 	if s.Config().GQLAPQCacheSize > 0 {
 		APQCacheSize = s.Config().GQLAPQCacheSize
 	}
+	var APQCache graphql.Cache = gqlLRU.New(APQCacheSize)
+	if s.Config().GQLAPQCacheProvider == "kv" {
+		store, err := support.OpenKVStore(s.Config().KVStorePath)
+		if err != nil {
+			s.logger.Errorf("[GQL] unable to open the APQ cache at '%s', falling back to memory: %s", s.Config().KVStorePath, err)
+		} else {
+			APQCache = &gqlAPQKVCache{s.logger, store, APQCacheSize, s.Config().GQLAPQCacheTTL}
+		}
+	}
+
 	gqlServer.Use(extension.AutomaticPersistedQuery{
-		Cache: gqlLRU.New(APQCacheSize),
+		Cache: APQCache,
 	})
 	gqlServer.Use(extension.FixedComplexityLimit(s.Config().GQLComplexityLimit))
 	gqlServer.Use(apollotracing.Tracer{})
@@ -268,11 +472,17 @@ func (s *Server) SetupGraphQL(path string, es graphql.ExecutableSchema, exts []g
 	}
 
 	s.router.Any(path, func(c *Context) {
-		gqlServer.ServeHTTP(c.Writer, c.Request)
+		ctx := context.WithValue(c.Request.Context(), gqlIntrospectionCtxKey, s.isGQLIntrospectionAllowed(c))
+		gqlServer.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
 	})
 
 	if s.config.GQLPlaygroundEnabled && s.config.GQLPlaygroundPath != "" {
 		s.router.GET(s.config.GQLPlaygroundPath, CSRFSkipCheck(), func(c *Context) {
+			if !s.isGQLIntrospectionAllowed(c) {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+
 			c.Data(http.StatusOK, "text/html; charset=utf-8", gqlPlaygroundTpl(path, c))
 		})
 	}
@@ -367,6 +577,47 @@ func (s *Server) SetupMailerPreview(m *mailer.Engine, i18n *support.I18n) {
 	})
 }
 
+// Warmup runs the warmup hooks one by one with the progress logging and marks
+// the server as warmed up once they are all done. Since warming up is only an
+// optimisation, the failed or timed out hook doesn't stop the server from
+// being warmed up and only the first error is returned. If the context is
+// cancelled, i.e. the server is shutting down, the remaining hooks are skipped
+// and the server is never marked as warmed up so that the health check keeps
+// responding with "503 Service Unavailable".
+func (s *Server) Warmup(ctx context.Context) error {
+	var firstErr error
+
+	for idx, hook := range s.warmupHooks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		s.logger.Infof("* Warming up '%s' (%d/%d)...", hook.name, idx+1, len(s.warmupHooks))
+
+		if err := hook.run(ctx); err != nil {
+			if ctx.Err() != nil {
+				s.logger.Infof("* Warming up '%s' (%d/%d)... CANCELLED in %s", hook.name, idx+1, len(s.warmupHooks), time.Since(start))
+				return ctx.Err()
+			}
+
+			s.logger.Errorf("* Warming up '%s' (%d/%d)... FAILED in %s: %s", hook.name, idx+1, len(s.warmupHooks), time.Since(start), err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		s.logger.Infof("* Warming up '%s' (%d/%d)... DONE in %s", hook.name, idx+1, len(s.warmupHooks), time.Since(start))
+	}
+
+	atomic.StoreInt32(&s.warmedUp, 1)
+
+	return firstErr
+}
+
 // TestHTTPRequest provides a simple way to fire HTTP request to the server.
 func (s *Server) TestHTTPRequest(method, path string, header H, body io.Reader) *ResponseRecorder {
 	w := NewResponseRecorder()
@@ -464,6 +715,85 @@ func (s *Server) spaResource(path string) *spaResource {
 	return resource
 }
 
+func (s *Server) csrfStrategy(path string) CSRFStrategy {
+	var (
+		matchedPrefix string
+		strategy      = CSRFStrategy(s.config.HTTPCSRFStrategy)
+	)
+
+	for prefix, prefixStrategy := range s.csrfStrategies {
+		isMatched := path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+		if isMatched && len(prefix) > len(matchedPrefix) {
+			matchedPrefix = prefix
+			strategy = prefixStrategy
+		}
+	}
+
+	return strategy
+}
+
+func (s *Server) isDocsAuthorized(c *Context) bool {
+	username, password, ok := c.Request.BasicAuth()
+
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(username), []byte(s.config.HTTPDocsUsername)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.config.HTTPDocsPassword)) != 1 {
+		c.Header("WWW-Authenticate", `Basic realm="docs"`)
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) isGQLIntrospectionAllowed(c *Context) bool {
+	if s.config.GQLIntrospectionEnabled {
+		return true
+	}
+
+	ip := s.gqlIntrospectionClientIP(c)
+	if ip == nil {
+		return false
+	}
+
+	return isIPInList(ip, s.config.GQLIntrospectionAllowlist)
+}
+
+// gqlIntrospectionClientIP returns the socket peer's IP, or the right-most IP
+// in "X-Forwarded-For" that isn't a trusted proxy if the peer is one, so that
+// the allowlist can't be bypassed by spoofing the request headers.
+func (s *Server) gqlIntrospectionClientIP(c *Context) net.IP {
+	peerAddr := c.Request.RemoteAddr
+	if addr, exists := c.Get(mdwRealIPPeerAddrCtxKey.String()); exists {
+		peerAddr = addr.(string)
+	}
+
+	host, _, err := net.SplitHostPort(peerAddr)
+	if err != nil {
+		host = peerAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isIPInList(ip, s.config.GQLIntrospectionTrustedProxies) {
+		return ip
+	}
+
+	xff := strings.Join(c.Request.Header.Values(xForwardedFor), ",")
+	if xff == "" {
+		return ip
+	}
+
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil || !isIPInList(ip, s.config.GQLIntrospectionTrustedProxies) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
 func (s *Server) isCSRPath(path string) bool {
 	for _, spaResource := range s.spaResources {
 		if strings.HasPrefix(path, spaResource.prefix) {
@@ -497,3 +827,23 @@ func (r *ResponseRecorder) CloseNotify() <-chan bool {
 func (r *ResponseRecorder) Close() {
 	r.closeChannel <- true
 }
+
+// isIPInList returns true if the IP matches any of the IP addresses or CIDR
+// blocks in the list.
+func isIPInList(ip net.IP, list []string) bool {
+	for _, item := range list {
+		if _, ipnet, err := net.ParseCIDR(item); err == nil {
+			if ipnet.Contains(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		if itemIP := net.ParseIP(item); itemIP != nil && itemIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
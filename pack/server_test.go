@@ -4,13 +4,17 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/appist/appy/mailer"
@@ -75,9 +79,9 @@ func (s *serverSuite) TestNewServerWithoutSSLEnabled() {
 }
 
 func (s *serverSuite) TestNewAppServer() {
-	server := NewAppServer(s.asset, s.config, s.i18n, s.mailer, s.logger, nil)
+	server := NewAppServer(s.asset, s.config, s.i18n, s.mailer, support.NewLifecycle(s.config, s.logger), s.logger, nil)
 
-	s.Equal(15, len(server.middleware))
+	s.Equal(18, len(server.middleware))
 }
 
 func (s *serverSuite) TestIsSSLCertsExisted() {
@@ -251,7 +255,7 @@ func (s *serverSuite) TestSetupGraphQL() {
 	s.config.GQLPlaygroundPath = "/graphiql"
 	graphqlPath := "/graphql"
 	server := NewServer(s.asset, s.config, s.logger)
-	server.Use(mdwCSRF(s.config, s.logger))
+	server.Use(mdwCSRF(s.config, s.logger, server))
 	server.SetupGraphQL(graphqlPath, nil, []graphql.HandlerExtension{fakeGQLExt{}})
 
 	w := server.TestHTTPRequest("GET", "/graphiql", nil, nil)
@@ -278,6 +282,194 @@ func (s *serverSuite) TestSetupGraphQL() {
 	s.NotNil(err)
 }
 
+func (s *serverSuite) TestSetupGraphQLWithIntrospectionDisabled() {
+	s.config.GQLIntrospectionEnabled = false
+	s.config.GQLIntrospectionAllowlist = []string{"10.0.0.0/8", "192.168.1.1", "invalid"}
+	s.config.GQLPlaygroundEnabled = true
+	s.config.GQLPlaygroundPath = "/graphiql"
+	server := NewServer(s.asset, s.config, s.logger)
+	server.SetupGraphQL("/graphql", nil, nil)
+
+	request := func(remoteAddr, xff string) *ResponseRecorder {
+		w := NewResponseRecorder()
+		req, _ := http.NewRequest("GET", "/graphiql", nil)
+		req.RemoteAddr = remoteAddr
+
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := server.TestHTTPRequest("GET", "/graphiql", nil, nil)
+	s.Equal(404, w.Code)
+
+	w = request("172.16.0.1:1234", "")
+	s.Equal(404, w.Code)
+
+	w = request("10.1.2.3:1234", "")
+	s.Equal(200, w.Code)
+	s.Contains(w.Body.String(), "<title>GraphQL Playground</title>")
+
+	w = request("192.168.1.1:1234", "")
+	s.Equal(200, w.Code)
+
+	// The spoofed "X-Forwarded-For" from an untrusted peer is ignored.
+	w = request("172.16.0.1:1234", "10.1.2.3")
+	s.Equal(404, w.Code)
+
+	s.config.GQLIntrospectionTrustedProxies = []string{"172.16.0.0/12"}
+	w = request("172.16.0.1:1234", "10.1.2.3")
+	s.Equal(200, w.Code)
+
+	w = request("172.16.0.1:1234", "10.1.2.3, 172.16.0.2")
+	s.Equal(200, w.Code)
+
+	// The client can only prepend to "X-Forwarded-For" which is appended by the
+	// trusted proxy.
+	w = request("172.16.0.1:1234", "10.1.2.3, 8.8.8.8")
+	s.Equal(404, w.Code)
+}
+
+func (s *serverSuite) TestGQLIntrospectionExtension() {
+	ext := gqlIntrospection{}
+
+	rc := &graphql.OperationContext{DisableIntrospection: true}
+	s.Nil(ext.MutateOperationContext(context.Background(), rc))
+	s.Equal(true, rc.DisableIntrospection)
+
+	rc = &graphql.OperationContext{DisableIntrospection: true}
+	ctx := context.WithValue(context.Background(), gqlIntrospectionCtxKey, false)
+	s.Nil(ext.MutateOperationContext(ctx, rc))
+	s.Equal(true, rc.DisableIntrospection)
+
+	rc = &graphql.OperationContext{DisableIntrospection: true}
+	ctx = context.WithValue(context.Background(), gqlIntrospectionCtxKey, true)
+	s.Nil(ext.MutateOperationContext(ctx, rc))
+	s.Equal(false, rc.DisableIntrospection)
+}
+
+func (s *serverSuite) TestGQLAPQKVCache() {
+	dir, err := ioutil.TempDir("", "apq")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	store, err := support.OpenKVStore(filepath.Join(dir, "appy.db"))
+	s.Nil(err)
+	defer store.Close()
+
+	cache := &gqlAPQKVCache{s.logger, store, 2, time.Hour}
+	_, ok := cache.Get(context.Background(), "foo")
+	s.Equal(false, ok)
+
+	cache.Add(context.Background(), "foo", "{ hello }")
+	cache.Add(context.Background(), "bar", 1)
+
+	query, ok := cache.Get(context.Background(), "foo")
+	s.Equal(true, ok)
+	s.Equal("{ hello }", query)
+
+	_, ok = cache.Get(context.Background(), "bar")
+	s.Equal(false, ok)
+
+	ttl, exists, err := store.TTL(gqlAPQCacheBucket, "foo")
+	s.Nil(err)
+	s.True(exists)
+	s.True(ttl > 59*time.Minute && ttl <= time.Hour)
+
+	cache.Add(context.Background(), "baz", "{ baz }")
+	cache.Add(context.Background(), "qux", "{ qux }")
+
+	_, ok = cache.Get(context.Background(), "foo")
+	s.Equal(false, ok)
+
+	for _, key := range []string{"baz", "qux"} {
+		_, ok = cache.Get(context.Background(), key)
+		s.Equal(true, ok)
+	}
+
+	cache = &gqlAPQKVCache{s.logger, store, 2, time.Nanosecond}
+	cache.Add(context.Background(), "expired", "{ expired }")
+	time.Sleep(time.Millisecond)
+
+	_, ok = cache.Get(context.Background(), "expired")
+	s.Equal(false, ok)
+}
+
+func (s *serverSuite) TestWarmup() {
+	server := NewAppServer(s.asset, s.config, s.i18n, s.mailer, nil, s.logger, nil)
+	s.Equal(true, server.IsWarmedUp())
+
+	calls := []string{}
+	server.OnWarmup("cache", 0, func(ctx context.Context) error {
+		calls = append(calls, "cache")
+		return nil
+	})
+	server.OnWarmup("flags", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	server.OnWarmup("templates", 0, func(ctx context.Context) error {
+		calls = append(calls, "templates")
+		return errors.New("template not found")
+	})
+	s.Equal(false, server.IsWarmedUp())
+	s.Equal(s.config.HTTPWarmupTimeout, server.warmupHooks[0].timeout)
+
+	w := server.TestHTTPRequest("GET", s.config.HTTPHealthCheckPath, nil, nil)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+
+	err := server.Warmup(context.Background())
+	s.EqualError(err, "timed out after 10ms")
+	s.Equal([]string{"cache", "templates"}, calls)
+	s.Equal(true, server.IsWarmedUp())
+
+	s.writer.Flush()
+	s.Contains(s.buffer.String(), "* Warming up 'cache' (1/3)... DONE in")
+	s.Contains(s.buffer.String(), "* Warming up 'flags' (2/3)... FAILED in")
+	s.Contains(s.buffer.String(), "* Warming up 'templates' (3/3)... FAILED in")
+	s.Contains(s.buffer.String(), "template not found")
+
+	w = server.TestHTTPRequest("GET", s.config.HTTPHealthCheckPath, nil, nil)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *serverSuite) TestWarmupCancelled() {
+	server := NewAppServer(s.asset, s.config, s.i18n, s.mailer, nil, s.logger, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := []string{}
+	server.OnWarmup("cache", 0, func(ctx context.Context) error {
+		calls = append(calls, "cache")
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	server.OnWarmup("templates", 0, func(ctx context.Context) error {
+		calls = append(calls, "templates")
+		return nil
+	})
+
+	err := server.Warmup(ctx)
+	s.Equal(context.Canceled, err)
+	s.Equal([]string{"cache"}, calls)
+	s.Equal(false, server.IsWarmedUp())
+
+	s.writer.Flush()
+	s.Contains(s.buffer.String(), "* Warming up 'cache' (1/2)... CANCELLED in")
+	s.NotContains(s.buffer.String(), "FAILED")
+	s.NotContains(s.buffer.String(), "'templates'")
+
+	w := server.TestHTTPRequest("GET", s.config.HTTPHealthCheckPath, nil, nil)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+
+	s.Equal(context.Canceled, server.Warmup(ctx))
+	s.Equal([]string{"cache"}, calls)
+	s.Equal(false, server.IsWarmedUp())
+}
+
 func TestServerSuite(t *testing.T) {
 	test.Run(t, new(serverSuite))
 }
@@ -19,6 +19,97 @@ const (
 	LiveReloadPath = "/reload"
 )
 
+func docsTpl() string {
+	return `
+	<!DOCTYPE html>
+	<html lang="en">
+		<head>
+			<meta charset="utf-8">
+			<meta name="viewport" content="width=device-width, initial-scale=1, shrink-to-fit=no">
+			<title>{{.Title}}</title>
+			<link href="//cdnjs.cloudflare.com/ajax/libs/twitter-bootstrap/4.3.1/css/bootstrap.min.css" rel="stylesheet" />
+			<style>
+			body { padding-top: 4.5rem; }
+			section { padding-top: 4.5rem; margin-top: -3rem; }
+			</style>
+		</head>
+		<body>
+			<nav class="navbar navbar-expand-md navbar-dark fixed-top bg-dark">
+				<div class="navbar-brand">{{.Title}}</div>
+				<ul class="navbar-nav mr-auto">
+					<li class="nav-item"><a class="nav-link" href="#routes">Routes</a></li>
+					<li class="nav-item"><a class="nav-link" href="#graphql">GraphQL</a></li>
+					<li class="nav-item"><a class="nav-link" href="#jobs">Jobs</a></li>
+					<li class="nav-item"><a class="nav-link" href="#mailers">Mailers</a></li>
+				</ul>
+				<a class="navbar-text small mr-3" href="{{.OpenAPIURL}}">OpenAPI</a>
+				<span class="navbar-text small">Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</span>
+			</nav>
+			<main role="main" class="container-fluid px-3">
+				<section id="routes">
+					<h2>Routes</h2>
+					<table class="table table-sm table-striped">
+						<thead>
+							<tr><th>Method</th><th>Path</th><th>Handler</th><th>Description</th></tr>
+						</thead>
+						<tbody>
+							{{range .Routes}}
+							<tr>
+								<td><code>{{.Method}}</code></td>
+								<td><code>{{.Path}}</code></td>
+								<td class="small">{{.Handler}}</td>
+								<td>
+									{{.Description}}
+									{{if .Request}}<details><summary class="small">Request</summary><pre class="bg-light p-2">{{.Request.String}}</pre></details>{{end}}
+									{{if .Response}}<details><summary class="small">Response</summary><pre class="bg-light p-2">{{.Response.String}}</pre></details>{{end}}
+								</td>
+							</tr>
+							{{else}}
+							<tr><td colspan="4" class="text-muted">No routes.</td></tr>
+							{{end}}
+						</tbody>
+					</table>
+				</section>
+				<section id="graphql">
+					<h2>GraphQL</h2>
+					{{range .GraphQL}}
+					<h5><code>{{.Path}}</code></h5>
+					<pre class="bg-light p-2">{{.SDL}}</pre>
+					{{else}}
+					<p class="text-muted">No GraphQL schemas.</p>
+					{{end}}
+				</section>
+				<section id="jobs">
+					<h2>Jobs</h2>
+					<ul class="list-group">
+						{{range .Jobs}}
+						<li class="list-group-item"><code>{{.}}</code></li>
+						{{else}}
+						<li class="list-group-item text-muted">No jobs.</li>
+						{{end}}
+					</ul>
+				</section>
+				<section id="mailers" class="mb-5">
+					<h2>Mailers</h2>
+					<table class="table table-sm table-striped">
+						<thead>
+							<tr><th>Template</th><th>Subject</th><th>Formats</th></tr>
+						</thead>
+						<tbody>
+							{{range .Mailers}}
+							<tr><td><code>{{.Template}}</code></td><td>{{.Subject}}</td><td>{{range $idx, $format := .Formats}}{{if $idx}}, {{end}}{{$format}}{{end}}</td></tr>
+							{{else}}
+							<tr><td colspan="3" class="text-muted">No mailers.</td></tr>
+							{{end}}
+						</tbody>
+					</table>
+				</section>
+			</main>
+		</body>
+	</html>
+	`
+}
+
 func errorTplUpper() string {
 	return `
 	<!DOCTYPE html>
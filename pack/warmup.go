@@ -0,0 +1,43 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type warmupHook struct {
+	name    string
+	timeout time.Duration
+	fn      WarmupHook
+}
+
+// run runs the hook with the timeout. The hook that doesn't respect the
+// context's cancellation is left running in the background so that it can't
+// block the server from being warmed up.
+func (h warmupHook) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", h.timeout)
+		}
+
+		return ctx.Err()
+	}
+}
@@ -25,6 +25,27 @@ type Config struct {
 	// assets on CDN. By default, it is "" which uses the current server host.
 	AssetHost string `env:"ASSET_HOST" envDefault:""`
 
+	// GQLIntrospectionEnabled indicates if the GraphQL introspection is enabled
+	// for all clients. By default, it is true.
+	//
+	// Note: When it is false, both the introspection and the GraphQL playground
+	// are only available to the clients in GQLIntrospectionAllowlist which is
+	// useful for disabling them in production.
+	GQLIntrospectionEnabled bool `env:"GQL_INTROSPECTION_ENABLED" envDefault:"true"`
+
+	// GQLIntrospectionAllowlist indicates a list of IP addresses or CIDR blocks,
+	// i.e. "10.0.0.1,192.168.0.0/16", of the internal clients that are allowed
+	// to use the GraphQL introspection and playground when
+	// GQLIntrospectionEnabled is false. By default, it is "".
+	GQLIntrospectionAllowlist []string `env:"GQL_INTROSPECTION_ALLOWLIST" envDefault:""`
+
+	// GQLIntrospectionTrustedProxies indicates a list of IP addresses or CIDR
+	// blocks, i.e. "172.16.0.0/12", of the reverse proxies/load balancers whose
+	// "X-Forwarded-For" request header is trusted to find out the client's IP
+	// for GQLIntrospectionAllowlist. Otherwise, only the socket peer's IP is
+	// checked. By default, it is "".
+	GQLIntrospectionTrustedProxies []string `env:"GQL_INTROSPECTION_TRUSTED_PROXIES" envDefault:""`
+
 	// GQLPlaygroundEnabled indicates if the GraphQL playground is enabled. By
 	// default, it is false.
 	GQLPlaygroundEnabled bool `env:"GQL_PLAYGROUND_ENABLED" envDefault:"false"`
@@ -33,11 +54,24 @@ type Config struct {
 	// default, it is "/docs/graphql".
 	GQLPlaygroundPath string `env:"GQL_PLAYGROUND_PATH" envDefault:"/docs/graphql"`
 
-	// GQLAPQCacheSize indicates how many APQ to persist in the memory at one
-	// time. By default, it is 100. For more details about APQ, please refer to
+	// GQLAPQCacheSize indicates how many APQ to persist in the memory or the
+	// KVStore at one time. By default, it is 100. For more details about APQ, please refer to
 	// https://gqlgen.com/reference/apq.
 	GQLAPQCacheSize int `env:"GQL_APQ_CACHE_SIZE" envDefault:"100"`
 
+	// GQLAPQCacheProvider indicates which store to use for caching the APQ. By
+	// default, it is "memory".
+	//
+	// Available options:
+	//   - memory
+	//   - kv (the APQ are persisted at KVStorePath for GQLAPQCacheTTL)
+	GQLAPQCacheProvider string `env:"GQL_APQ_CACHE_PROVIDER" envDefault:"memory"`
+
+	// GQLAPQCacheTTL indicates how long to persist each APQ when
+	// GQLAPQCacheProvider is "kv". By default, it is 24h. If it is 0, the APQ
+	// never expire but are still capped by GQLAPQCacheSize.
+	GQLAPQCacheTTL time.Duration `env:"GQL_APQ_CACHE_TTL" envDefault:"24h"`
+
 	// GQLQueryCacheSize indicates how many queries to cache in the memory. By
 	// default, it is 1000.
 	GQLQueryCacheSize int `env:"GQL_QUERY_CACHE_SIZE" envDefault:"1000"`
@@ -92,6 +126,43 @@ type Config struct {
 	// ready to receive HTTP requests.
 	HTTPHealthCheckPath string `env:"HTTP_HEALTH_CHECK_PATH" envDefault:"/health_check"`
 
+	// HTTPWarmupTimeout indicates how long each warmup hook that is registered
+	// via OnWarmup without its own timeout can run before the health check
+	// flips healthy. By default, it is "30s".
+	HTTPWarmupTimeout time.Duration `env:"HTTP_WARMUP_TIMEOUT" envDefault:"30s"`
+
+	// HTTPDocsEnabled indicates if the documentation that is generated from the
+	// routes, GraphQL schemas, background jobs and mailer templates should be
+	// served at HTTPDocsPath. By default, it is false.
+	HTTPDocsEnabled bool `env:"HTTP_DOCS_ENABLED" envDefault:"false"`
+
+	// HTTPDocsPath indicates the path to serve the documentation at. By default,
+	// it is "/docs".
+	HTTPDocsPath string `env:"HTTP_DOCS_PATH" envDefault:"/docs"`
+
+	// HTTPDocsUsername indicates the basic authentication username to access
+	// the documentation. The documentation is not served if HTTPDocsUsername or
+	// HTTPDocsPassword is empty. By default, it is "".
+	HTTPDocsUsername string `env:"HTTP_DOCS_USERNAME" envDefault:""`
+
+	// HTTPDocsPassword indicates the basic authentication password to access
+	// the documentation. By default, it is "".
+	HTTPDocsPassword string `env:"HTTP_DOCS_PASSWORD" envDefault:""`
+
+	// HTTPSchemaMaxBodySize indicates the maximum number of bytes of the JSON
+	// request body that is read for the validation against the route's request
+	// schema that is set via SetRouteSchema. The request that exceeds it will be
+	// rejected with "413 Request Entity Too Large". By default, it is 1048576.
+	//
+	// Note: 0 means no limit.
+	HTTPSchemaMaxBodySize int64 `env:"HTTP_SCHEMA_MAX_BODY_SIZE" envDefault:"1048576"`
+
+	// HTTPSchemaValidateResponse indicates if the JSON response body should be
+	// validated against the route's response schema that is set via
+	// SetRouteSchema. The mismatch is only logged as warning since the response
+	// is already sent. By default, it is false.
+	HTTPSchemaValidateResponse bool `env:"HTTP_SCHEMA_VALIDATE_RESPONSE" envDefault:"false"`
+
 	// HTTPHost indicates which host the HTTP server should be hosted at. By
 	// default, it is "localhost". If you would like to connect to the HTTP server
 	// from within your LAN network, use "0.0.0.0" instead.
@@ -112,12 +183,41 @@ type Config struct {
 	// it is "75s".
 	HTTPIdleTimeout time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"75s"`
 
+	// HTTPMaintenanceRetryAfter indicates how long the clients should wait
+	// before retrying the request via the "Retry-After" header when the server
+	// is in the maintenance mode. By default, it is "2m".
+	//
+	// Note: 0 means the "Retry-After" header is not set.
+	HTTPMaintenanceRetryAfter time.Duration `env:"HTTP_MAINTENANCE_RETRY_AFTER" envDefault:"2m"`
+
 	// HTTPMaxHeaderBytes controls the maximum number of bytes the server will read
 	// parsing the request header's keys and values, including the request line.
 	// It does not limit the size of the request body. If zero,
 	// http.DefaultMaxHeaderBytes (1 << 20 which is 1 MB) is used.
 	HTTPMaxHeaderBytes int `env:"HTTP_MAX_HEADER_BYTES" envDefault:"0"`
 
+	// HTTPMaxHeaderCount indicates the maximum number of request headers that
+	// the HTTP server will process. The request that exceeds it will be rejected
+	// with "431 Request Header Fields Too Large". By default, it is 100.
+	//
+	// Note: 0 means no limit.
+	HTTPMaxHeaderCount int `env:"HTTP_MAX_HEADER_COUNT" envDefault:"100"`
+
+	// HTTPMaxHeaderSize indicates the maximum number of bytes of a single request
+	// header's key and values that the HTTP server will process. The request
+	// that exceeds it will be rejected with "431 Request Header Fields Too Large".
+	// By default, it is 8192.
+	//
+	// Note: 0 means no limit.
+	HTTPMaxHeaderSize int `env:"HTTP_MAX_HEADER_SIZE" envDefault:"8192"`
+
+	// HTTPMaxURLLength indicates the maximum number of bytes of the request URI
+	// that the HTTP server will process. The request that exceeds it will be
+	// rejected with "414 URI Too Long". By default, it is 8192.
+	//
+	// Note: 0 means no limit.
+	HTTPMaxURLLength int `env:"HTTP_MAX_URL_LENGTH" envDefault:"8192"`
+
 	// HTTPReadTimeout is the maximum duration for reading the entire request,
 	// including the body. Because HTTPReadTimeout does not let Handlers make
 	// per-request decisions on each request body's acceptable deadline or upload
@@ -157,6 +257,7 @@ type Config struct {
 	//
 	// Available options:
 	//   - cookie
+	//   - kv
 	//   - redis
 	HTTPSessionProvider string `env:"HTTP_SESSION_PROVIDER" envDefault:"cookie"`
 
@@ -256,6 +357,24 @@ type Config struct {
 	// allows all domain names.
 	HTTPAllowedHosts []string `env:"HTTP_ALLOWED_HOSTS" envDefault:""`
 
+	// HTTPCSRFStrategy indicates how the CSRF check verifies the unsafe requests.
+	// By default, it is "token".
+	//
+	// Available options:
+	//   - token:  verifies the authenticity token which works for all browsers
+	//   - origin: verifies the "Sec-Fetch-Site" or "Origin" request header which
+	//             only works for modern browsers but doesn't require the
+	//             authenticity token
+	//
+	// Note: The strategy can be overridden for a route group with
+	// `server.SetCSRFStrategy()`.
+	HTTPCSRFStrategy string `env:"HTTP_CSRF_STRATEGY" envDefault:"token"`
+
+	// HTTPCSRFTrustedOrigins indicates a list of origins, i.e.
+	// "https://app.example.com", that are allowed to send cross-origin requests
+	// when HTTPCSRFStrategy is "origin". By default, it is "".
+	HTTPCSRFTrustedOrigins []string `env:"HTTP_CSRF_TRUSTED_ORIGINS" envDefault:""`
+
 	// HTTPCSRFCookieDomain indicates which domain the CSRF cookie can be sent
 	// to. By default, it is "localhost".
 	HTTPCSRFCookieDomain string `env:"HTTP_CSRF_COOKIE_DOMAIN" envDefault:"localhost"`
@@ -365,6 +484,47 @@ type Config struct {
 	// Note: If the locale is "en", the translation file would be "pkg/locales/en.yml".
 	I18nDefaultLocale string `env:"I18N_DEFAULT_LOCALE" envDefault:"en"`
 
+	// KVStorePath indicates the file path of the embedded key-value store which
+	// is used by the "kv" provider of the APQ cache, session store and worker
+	// so that a single binary can run without any external service. By
+	// default, it is "./tmp/appy.db".
+	//
+	// Note: The file is locked by the process that opens it which means the
+	// "kv" worker can only process the jobs within the `serve` command.
+	KVStorePath string `env:"KV_STORE_PATH" envDefault:"./tmp/appy.db"`
+
+	// KVStorePurgeInterval indicates how often the `serve` command deletes the
+	// expired keys in the embedded key-value store when any "kv" provider is
+	// used. By default, it is 10m. If it is 0, the expired keys are only
+	// deleted lazily when they are read.
+	KVStorePurgeInterval time.Duration `env:"KV_STORE_PURGE_INTERVAL" envDefault:"10m"`
+
+	// LifecycleWebhookURLs indicates a list of URLs to send the application
+	// lifecycle events to via HTTP POST with a JSON payload. By default, it is
+	// "" which doesn't send any webhook.
+	LifecycleWebhookURLs []string `env:"LIFECYCLE_WEBHOOK_URLS" envDefault:""`
+
+	// LifecycleWebhookEvents indicates which application lifecycle events to
+	// send, i.e. "app.boot,app.shutdown". By default, it is "" which sends all
+	// the events.
+	//
+	// Available options:
+	//   - app.boot
+	//   - app.maintenance.enter
+	//   - app.maintenance.exit
+	//   - app.shutdown
+	//   - db.migrate
+	LifecycleWebhookEvents []string `env:"LIFECYCLE_WEBHOOK_EVENTS" envDefault:""`
+
+	// LifecycleWebhookSecret indicates the secret to sign the webhook payload
+	// with HMAC-SHA256 which is sent in the "X-Appy-Signature" request header.
+	// By default, it is "" which doesn't sign the payload.
+	LifecycleWebhookSecret []byte `env:"LIFECYCLE_WEBHOOK_SECRET" envDefault:""`
+
+	// LifecycleWebhookTimeout indicates how long to wait for the webhooks of an
+	// event, which are sent concurrently, to respond. By default, it is "5s".
+	LifecycleWebhookTimeout time.Duration `env:"LIFECYCLE_WEBHOOK_TIMEOUT" envDefault:"5s"`
+
 	// MailerSMTPAddr indicates the SMTP server hostname that sends out email.
 	// By default, it is "".
 	MailerSMTPAddr string `env:"MAILER_SMTP_ADDR" envDefault:""`
@@ -391,6 +551,14 @@ type Config struct {
 	// it is "/appy/mailers".
 	MailerPreviewPath string `env:"MAILER_PREVIEW_PATH" envDefault:"/appy/mailers"`
 
+	// WorkerProvider indicates which queue to use for the background jobs. By
+	// default, it is "redis".
+	//
+	// Available options:
+	//   - kv (the jobs are processed by the `serve` command)
+	//   - redis
+	WorkerProvider string `env:"WORKER_PROVIDER" envDefault:"redis"`
+
 	// WorkerRedisSentinelAddrs indicates the Redis sentinel hosts to connect to.
 	// By default, it is "".
 	//
@@ -485,6 +653,8 @@ func NewConfig(asset AssetManager, logger *Logger) *Config {
 
 		if err := ParseEnv(config); err != nil {
 			config.errors = append(config.errors, err)
+		} else if !ArrayContains([]string{"token", "origin"}, config.HTTPCSRFStrategy) {
+			config.errors = append(config.errors, ErrInvalidCSRFStrategy)
 		}
 	}
 
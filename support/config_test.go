@@ -30,9 +30,14 @@ func (s *configSuite) TestDefaultValue() {
 	tt := map[string]interface{}{
 		"AppyEnv":                            "development",
 		"AssetHost":                          "",
+		"GQLIntrospectionEnabled":            true,
+		"GQLIntrospectionAllowlist":          []string{},
+		"GQLIntrospectionTrustedProxies":     []string{},
 		"GQLPlaygroundEnabled":               false,
 		"GQLPlaygroundPath":                  "/docs/graphql",
 		"GQLAPQCacheSize":                    100,
+		"GQLAPQCacheProvider":                "memory",
+		"GQLAPQCacheTTL":                     24 * time.Hour,
 		"GQLQueryCacheSize":                  1000,
 		"GQLComplexityLimit":                 1000,
 		"GQLMultipartMaxMemory":              int64(0),
@@ -42,11 +47,22 @@ func (s *configSuite) TestDefaultValue() {
 		"HTTPGzipExcludedExts":               []string{},
 		"HTTPLogFilterParameters":            []string{"password"},
 		"HTTPHealthCheckPath":                "/health_check",
+		"HTTPWarmupTimeout":                  30 * time.Second,
+		"HTTPDocsEnabled":                    false,
+		"HTTPDocsPath":                       "/docs",
+		"HTTPDocsUsername":                   "",
+		"HTTPDocsPassword":                   "",
+		"HTTPSchemaMaxBodySize":              int64(1048576),
+		"HTTPSchemaValidateResponse":         false,
 		"HTTPHost":                           "localhost",
 		"HTTPPort":                           "3000",
 		"HTTPGracefulShutdownTimeout":        30 * time.Second,
 		"HTTPIdleTimeout":                    75 * time.Second,
+		"HTTPMaintenanceRetryAfter":          2 * time.Minute,
 		"HTTPMaxHeaderBytes":                 0,
+		"HTTPMaxHeaderCount":                 100,
+		"HTTPMaxHeaderSize":                  8192,
+		"HTTPMaxURLLength":                   8192,
 		"HTTPReadTimeout":                    60 * time.Second,
 		"HTTPReadHeaderTimeout":              60 * time.Second,
 		"HTTPWriteTimeout":                   60 * time.Second,
@@ -71,6 +87,8 @@ func (s *configSuite) TestDefaultValue() {
 		"HTTPSessionCookieSameSite":          http.SameSite(1),
 		"HTTPSessionCookieSecure":            false,
 		"HTTPAllowedHosts":                   []string{},
+		"HTTPCSRFStrategy":                   "token",
+		"HTTPCSRFTrustedOrigins":             []string{},
 		"HTTPCSRFCookieDomain":               "localhost",
 		"HTTPCSRFCookieHTTPOnly":             true,
 		"HTTPCSRFCookieMaxAge":               0,
@@ -95,12 +113,19 @@ func (s *configSuite) TestDefaultValue() {
 		"HTTPIENoOpen":                       false,
 		"HTTPSSLProxyHeaders":                map[string]string{"X-Forwarded-Proto": "https"},
 		"I18nDefaultLocale":                  "en",
+		"KVStorePath":                        "./tmp/appy.db",
+		"KVStorePurgeInterval":               10 * time.Minute,
+		"LifecycleWebhookURLs":               []string{},
+		"LifecycleWebhookEvents":             []string{},
+		"LifecycleWebhookSecret":             []byte{},
+		"LifecycleWebhookTimeout":            5 * time.Second,
 		"MailerSMTPAddr":                     "",
 		"MailerSMTPPlainAuthIdentity":        "",
 		"MailerSMTPPlainAuthUsername":        "",
 		"MailerSMTPPlainAuthPassword":        "",
 		"MailerSMTPPlainAuthHost":            "",
 		"MailerPreviewPath":                  "/appy/mailers",
+		"WorkerProvider":                     "redis",
 		"WorkerRedisSentinelAddrs":           []string{},
 		"WorkerRedisSentinelDB":              0,
 		"WorkerRedisSentinelMasterName":      "",
@@ -332,6 +357,26 @@ func (s *configSuite) TestConfigFileParsing() {
 		s.Equal(0, len(config.Errors()))
 		s.Equal("1.2.3.4", config.HTTPHost)
 	}
+
+	{
+		os.Setenv("APPY_ENV", "decryptable")
+		os.Setenv("APPY_MASTER_KEY", "5a9f28ee6301fbaee87d27a9af5cbdc73f3e907f0dec11a4f37e361c1e0687da")
+		os.Setenv("HTTP_CSRF_SECRET", "58f364f29b568807ab9cffa22c99b538")
+		os.Setenv("HTTP_CSRF_STRATEGY", "referer")
+		os.Setenv("HTTP_SESSION_SECRETS", "58f364f29b568807ab9cffa22c99b538")
+		defer func() {
+			os.Unsetenv("APPY_ENV")
+			os.Unsetenv("APPY_MASTER_KEY")
+			os.Unsetenv("HTTP_CSRF_SECRET")
+			os.Unsetenv("HTTP_CSRF_STRATEGY")
+			os.Unsetenv("HTTP_SESSION_SECRETS")
+		}()
+
+		asset := NewAsset(nil, "testdata/config/config_file_parsing")
+		config := NewConfig(asset, s.logger)
+
+		s.Equal(ErrInvalidCSRFStrategy, config.Errors()[0])
+	}
 }
 
 func TestConfigSuite(t *testing.T) {
@@ -3,6 +3,10 @@ package support
 import "errors"
 
 var (
+	// ErrInvalidCSRFStrategy indicates HTTP_CSRF_STRATEGY is neither "token"
+	// nor "origin".
+	ErrInvalidCSRFStrategy = errors.New("HTTP_CSRF_STRATEGY must be either 'token' or 'origin'")
+
 	// ErrMissingMasterKey indicates the master key is not provided.
 	ErrMissingMasterKey = errors.New("master key is missing")
 
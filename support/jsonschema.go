@@ -0,0 +1,499 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// JSONSchema is the JSON schema that describes the JSON request/response
+	// body which is compatible with both JSON Schema and OpenAPI 3.0.
+	JSONSchema struct {
+		Type                 string                 `json:"type,omitempty"`
+		Format               string                 `json:"format,omitempty"`
+		Enum                 []interface{}          `json:"enum,omitempty"`
+		Pattern              string                 `json:"pattern,omitempty"`
+		Minimum              *float64               `json:"minimum,omitempty"`
+		Maximum              *float64               `json:"maximum,omitempty"`
+		MinLength            *int                   `json:"minLength,omitempty"`
+		MaxLength            *int                   `json:"maxLength,omitempty"`
+		MinItems             *int                   `json:"minItems,omitempty"`
+		MaxItems             *int                   `json:"maxItems,omitempty"`
+		Items                *JSONSchema            `json:"items,omitempty"`
+		Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+		AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+		Required             []string               `json:"required,omitempty"`
+
+		// OmitEmpty indicates the zero value, i.e. "", 0, false or [], skips
+		// the rest of the validation rules like the "omitempty" binding rule.
+		OmitEmpty bool `json:"x-omitempty,omitempty"`
+	}
+
+	// JSONSchemaError indicates the JSON value at the path, i.e.
+	// "user.emails[0]", doesn't match the JSON schema.
+	JSONSchemaError struct {
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	}
+)
+
+var (
+	jsonSchemaEmailRegex = regexp.MustCompile(`^[^\s@]+@[^\s@]+$`)
+	jsonSchemaUUIDRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	jsonSchemaPatterns = map[string]string{
+		"alpha":    "^[a-zA-Z]+$",
+		"alphanum": "^[a-zA-Z0-9]+$",
+		"numeric":  "^[-+]?[0-9]+(?:\\.[0-9]+)?$",
+	}
+
+	jsonSchemaFormats = map[string]string{
+		"email": "email",
+		"uri":   "uri",
+		"url":   "uri",
+		"uuid":  "uuid",
+		"uuid4": "uuid",
+	}
+
+	// jsonSchemaRegexes caches the compiled patterns so that they are compiled
+	// once when the schema is built instead of for every validated value.
+	jsonSchemaRegexes = &sync.Map{}
+
+	jsonSchemaTimeType = reflect.TypeOf(time.Time{})
+)
+
+// NewJSONSchema derives the JSON schema from the value's type by using the
+// "json" struct tag for the property names and the "binding" struct tag that
+// is used by the request binding for the validation rules, i.e. required,
+// min, max, len, oneof, email, url and uuid.
+func NewJSONSchema(v interface{}) *JSONSchema {
+	if v == nil {
+		return nil
+	}
+
+	return newJSONSchema(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+func newJSONSchema(t reflect.Type, seen map[reflect.Type]bool) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if schema := newJSONSchemaFromNullType(t); schema != nil {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := float64(0)
+		return &JSONSchema{Type: "integer", Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+
+		return &JSONSchema{Type: "array", Items: newJSONSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: newJSONSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if t == jsonSchemaTimeType {
+			return &JSONSchema{Type: "string", Format: "date-time"}
+		}
+
+		// Stop at the recursive type to avoid the infinite loop.
+		if seen[t] {
+			return &JSONSchema{Type: "object"}
+		}
+
+		seen[t] = true
+		defer delete(seen, t)
+
+		schema := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+		addJSONSchemaProperties(schema, t, seen)
+
+		return schema
+	}
+
+	// The interface{} can be any JSON value.
+	return &JSONSchema{}
+}
+
+func newJSONSchemaFromNullType(t reflect.Type) *JSONSchema {
+	switch t {
+	case reflect.TypeOf(NBool{}), reflect.TypeOf(ZBool{}):
+		return &JSONSchema{Type: "boolean"}
+	case reflect.TypeOf(NFloat64{}), reflect.TypeOf(ZFloat64{}):
+		return &JSONSchema{Type: "number"}
+	case reflect.TypeOf(NInt64{}), reflect.TypeOf(ZInt64{}):
+		return &JSONSchema{Type: "integer"}
+	case reflect.TypeOf(NString{}), reflect.TypeOf(ZString{}):
+		return &JSONSchema{Type: "string"}
+	case reflect.TypeOf(NTime{}), reflect.TypeOf(ZTime{}):
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	}
+
+	return nil
+}
+
+func addJSONSchemaProperties(schema *JSONSchema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		// The embedded struct's fields are promoted to the parent unless it has
+		// the JSON name.
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				addJSONSchemaProperties(schema, embedded, seen)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := newJSONSchema(field.Type, seen)
+		if applyJSONSchemaRules(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+
+		schema.Properties[name] = property
+	}
+}
+
+// applyJSONSchemaRules applies the validation rules from the "binding" struct
+// tag and returns true if the field is required.
+func applyJSONSchemaRules(schema *JSONSchema, tag string) bool {
+	required := false
+
+	rules := strings.Split(tag, ",")
+
+	for idx, rule := range rules {
+		splits := strings.SplitN(rule, "=", 2)
+		name, param := splits[0], ""
+		if len(splits) == 2 {
+			param = splits[1]
+		}
+
+		switch name {
+		case "dive":
+			// The rest of the rules are for the array items.
+			if schema.Items != nil {
+				applyJSONSchemaRules(schema.Items, strings.Join(rules[idx+1:], ","))
+			}
+
+			return required
+		case "required":
+			required = true
+		case "omitempty":
+			schema.OmitEmpty = true
+		case "len":
+			applyJSONSchemaRange(schema, param, true, true)
+		case "min", "gte":
+			applyJSONSchemaRange(schema, param, true, false)
+		case "max", "lte":
+			applyJSONSchemaRange(schema, param, false, true)
+		case "oneof":
+			for _, val := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, parseJSONSchemaValue(schema.Type, val))
+			}
+		default:
+			if format, ok := jsonSchemaFormats[name]; ok {
+				schema.Format = format
+			}
+
+			if pattern, ok := jsonSchemaPatterns[name]; ok {
+				jsonSchemaRegex(pattern)
+				schema.Pattern = pattern
+			}
+		}
+	}
+
+	return required
+}
+
+func applyJSONSchemaRange(schema *JSONSchema, param string, min, max bool) {
+	val, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch schema.Type {
+	case "string":
+		length := int(val)
+		if min {
+			schema.MinLength = &length
+		}
+
+		if max {
+			schema.MaxLength = &length
+		}
+	case "array":
+		length := int(val)
+		if min {
+			schema.MinItems = &length
+		}
+
+		if max {
+			schema.MaxItems = &length
+		}
+	case "integer", "number":
+		if min {
+			schema.Minimum = &val
+		}
+
+		if max {
+			schema.Maximum = &val
+		}
+	}
+}
+
+func parseJSONSchemaValue(schemaType, val string) interface{} {
+	switch schemaType {
+	case "integer", "number":
+		if num, err := strconv.ParseFloat(val, 64); err == nil {
+			return num
+		}
+	}
+
+	return val
+}
+
+// String returns the JSON schema in indented JSON.
+func (s *JSONSchema) String() string {
+	data, _ := json.MarshalIndent(s, "", "  ")
+
+	return string(data)
+}
+
+// Validate validates the decoded JSON value, i.e. from json.Unmarshal into
+// interface{}, against the JSON schema.
+func (s *JSONSchema) Validate(val interface{}) []JSONSchemaError {
+	errs := []JSONSchemaError{}
+	s.validate("", val, &errs)
+
+	return errs
+}
+
+func (s *JSONSchema) validate(path string, val interface{}, errs *[]JSONSchemaError) {
+	addError := func(format string, args ...interface{}) {
+		*errs = append(*errs, JSONSchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if val == nil {
+		if s.Type != "" {
+			addError("must be %s", s.Type)
+		}
+
+		return
+	}
+
+	if s.OmitEmpty && isJSONSchemaZero(s.Type, val) {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			addError("must be object")
+			return
+		}
+
+		for _, name := range s.Required {
+			if prop, exists := obj[name]; !exists || prop == nil {
+				*errs = append(*errs, JSONSchemaError{Path: joinJSONSchemaPath(path, name), Message: "is required"})
+			}
+		}
+
+		names := []string{}
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prop := obj[name]
+
+			// The optional property can be null as it is decoded into the zero
+			// value.
+			if prop == nil {
+				continue
+			}
+
+			if schema, ok := s.Properties[name]; ok {
+				schema.validate(joinJSONSchemaPath(path, name), prop, errs)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(joinJSONSchemaPath(path, name), prop, errs)
+			}
+		}
+	case "array":
+		arr, ok := val.([]interface{})
+		if !ok {
+			addError("must be array")
+			return
+		}
+
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			addError("must have at least %d items", *s.MinItems)
+		}
+
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			addError("must have at most %d items", *s.MaxItems)
+		}
+
+		if s.Items != nil {
+			for idx, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, idx), item, errs)
+			}
+		}
+	case "string":
+		str, ok := val.(string)
+		if !ok {
+			addError("must be string")
+			return
+		}
+
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			addError("must be at least %d characters long", *s.MinLength)
+		}
+
+		if s.MaxLength != nil && length > *s.MaxLength {
+			addError("must be at most %d characters long", *s.MaxLength)
+		}
+
+		if s.Pattern != "" {
+			if regex, err := jsonSchemaRegex(s.Pattern); err != nil || !regex.MatchString(str) {
+				addError("must match the pattern '%s'", s.Pattern)
+			}
+		}
+
+		if !isJSONSchemaFormat(s.Format, str) {
+			addError("must be a valid %s", s.Format)
+		}
+	case "integer", "number":
+		num, ok := val.(float64)
+		if !ok || (s.Type == "integer" && num != math.Trunc(num)) {
+			addError("must be %s", s.Type)
+			return
+		}
+
+		if s.Minimum != nil && num < *s.Minimum {
+			addError("must be greater than or equal to %v", *s.Minimum)
+		}
+
+		if s.Maximum != nil && num > *s.Maximum {
+			addError("must be less than or equal to %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			addError("must be boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, enum := range s.Enum {
+			if reflect.DeepEqual(enum, val) {
+				return
+			}
+		}
+
+		addError("must be one of %v", s.Enum)
+	}
+}
+
+// isJSONSchemaZero checks if the value is the zero value of the schema type
+// which is what the request binding decodes into for the "omitempty" rule.
+func isJSONSchemaZero(schemaType string, val interface{}) bool {
+	switch v := val.(type) {
+	case string:
+		return schemaType == "string" && v == ""
+	case float64:
+		return (schemaType == "integer" || schemaType == "number") && v == 0
+	case bool:
+		return schemaType == "boolean" && !v
+	case []interface{}:
+		return schemaType == "array" && len(v) == 0
+	case map[string]interface{}:
+		return schemaType == "object" && len(v) == 0
+	}
+
+	return false
+}
+
+func isJSONSchemaFormat(format, val string) bool {
+	switch format {
+	case "email":
+		return jsonSchemaEmailRegex.MatchString(val)
+	case "uri":
+		u, err := url.ParseRequestURI(val)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return jsonSchemaUUIDRegex.MatchString(val)
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, val)
+		return err == nil
+	}
+
+	return true
+}
+
+func joinJSONSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// jsonSchemaRegex returns the compiled pattern from the cache or compiles it
+// if it isn't cached yet, i.e. the schema that isn't built by NewJSONSchema.
+func jsonSchemaRegex(pattern string) (*regexp.Regexp, error) {
+	if regex, ok := jsonSchemaRegexes.Load(pattern); ok {
+		return regex.(*regexp.Regexp), nil
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonSchemaRegexes.Store(pattern, regex)
+	return regex, nil
+}
@@ -0,0 +1,175 @@
+package support
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/test"
+)
+
+type jsonSchemaSuite struct {
+	test.Suite
+}
+
+type (
+	jsonSchemaTimestamps struct {
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	jsonSchemaProfile struct {
+		Bio    NString            `json:"bio"`
+		Links  map[string]string  `json:"links"`
+		Parent *jsonSchemaProfile `json:"parent"`
+	}
+
+	jsonSchemaUser struct {
+		jsonSchemaTimestamps
+		Email    string             `json:"email" binding:"required,email"`
+		Name     string             `json:"name" binding:"required,min=2,max=20"`
+		Age      uint               `json:"age" binding:"max=150"`
+		Role     string             `json:"role" binding:"oneof=admin member"`
+		Tags     []string           `json:"tags" binding:"max=3,dive,alpha"`
+		Profile  *jsonSchemaProfile `json:"profile"`
+		Password string             `json:"-"`
+		internal string
+	}
+
+	jsonSchemaContact struct {
+		Email  string   `json:"email" binding:"omitempty,email"`
+		Name   string   `json:"name" binding:"omitempty,min=3"`
+		Age    int      `json:"age" binding:"omitempty,min=18"`
+		Role   string   `json:"role" binding:"omitempty,oneof=admin member"`
+		Tags   []string `json:"tags" binding:"omitempty,min=1,dive,omitempty,alpha"`
+		Code   string   `json:"code" binding:"omitempty,alphanum"`
+		Active bool     `json:"active" binding:"omitempty"`
+	}
+)
+
+func (s *jsonSchemaSuite) TestNewJSONSchema() {
+	s.Nil(NewJSONSchema(nil))
+
+	schema := NewJSONSchema(&jsonSchemaUser{})
+	s.Equal("object", schema.Type)
+	s.Equal([]string{"email", "name"}, schema.Required)
+	s.Equal(7, len(schema.Properties))
+	s.Nil(schema.Properties["Password"])
+	s.Nil(schema.Properties["internal"])
+
+	s.Equal(&JSONSchema{Type: "string", Format: "date-time"}, schema.Properties["createdAt"])
+	s.Equal(&JSONSchema{Type: "string", Format: "email"}, schema.Properties["email"])
+	s.Equal(2, *schema.Properties["name"].MinLength)
+	s.Equal(20, *schema.Properties["name"].MaxLength)
+	s.Equal(float64(0), *schema.Properties["age"].Minimum)
+	s.Equal(float64(150), *schema.Properties["age"].Maximum)
+	s.Equal([]interface{}{"admin", "member"}, schema.Properties["role"].Enum)
+	s.Equal(3, *schema.Properties["tags"].MaxItems)
+	s.Equal(&JSONSchema{Type: "string", Pattern: "^[a-zA-Z]+$"}, schema.Properties["tags"].Items)
+
+	profile := schema.Properties["profile"]
+	s.Equal(&JSONSchema{Type: "string"}, profile.Properties["bio"])
+	s.Equal(&JSONSchema{Type: "object", AdditionalProperties: &JSONSchema{Type: "string"}}, profile.Properties["links"])
+	s.Equal(&JSONSchema{Type: "object"}, profile.Properties["parent"])
+
+	data, err := json.Marshal(NewJSONSchema([]int{}))
+	s.Nil(err)
+	s.Equal(`{"type":"array","items":{"type":"integer"}}`, string(data))
+}
+
+func (s *jsonSchemaSuite) TestValidate() {
+	schema := NewJSONSchema(jsonSchemaUser{})
+
+	tt := []struct {
+		body string
+		errs []JSONSchemaError
+	}{
+		{
+			`{"email":"john@example.com","name":"John"}`,
+			[]JSONSchemaError{},
+		},
+		{
+			`{"email":"john@example.com","name":"John","createdAt":"2020-01-01T00:00:00Z","profile":{"bio":null,"links":{"github":"john"}}}`,
+			[]JSONSchemaError{},
+		},
+		{
+			`[]`,
+			[]JSONSchemaError{{"", "must be object"}},
+		},
+		{
+			`{"name":null}`,
+			[]JSONSchemaError{{"email", "is required"}, {"name", "is required"}},
+		},
+		{
+			`{"email":"john","name":"J","age":-1.5,"role":"owner","tags":["a","b1","c","d"],"createdAt":"today"}`,
+			[]JSONSchemaError{
+				{"age", "must be integer"},
+				{"createdAt", "must be a valid date-time"},
+				{"email", "must be a valid email"},
+				{"name", "must be at least 2 characters long"},
+				{"role", "must be one of [admin member]"},
+				{"tags", "must have at most 3 items"},
+				{"tags[1]", "must match the pattern '^[a-zA-Z]+$'"},
+			},
+		},
+		{
+			`{"email":"john@example.com","name":"John","age":151,"profile":{"bio":1,"links":{"github":true}}}`,
+			[]JSONSchemaError{
+				{"age", "must be less than or equal to 150"},
+				{"profile.bio", "must be string"},
+				{"profile.links.github", "must be string"},
+			},
+		},
+	}
+
+	for _, t := range tt {
+		var body interface{}
+		s.Nil(json.Unmarshal([]byte(t.body), &body))
+		s.Equal(t.errs, schema.Validate(body))
+	}
+}
+
+func (s *jsonSchemaSuite) TestValidateOmitEmpty() {
+	schema := NewJSONSchema(jsonSchemaContact{})
+	s.Equal(true, schema.Properties["email"].OmitEmpty)
+	s.Equal(true, schema.Properties["tags"].Items.OmitEmpty)
+	s.Contains(schema.Properties["email"].String(), `"x-omitempty": true`)
+
+	tt := []struct {
+		body string
+		errs []JSONSchemaError
+	}{
+		{
+			`{"email":"","name":"","age":0,"role":"","tags":[],"code":"","active":false}`,
+			[]JSONSchemaError{},
+		},
+		{
+			`{"tags":["a",""]}`,
+			[]JSONSchemaError{},
+		},
+		{
+			`{"email":"john","name":"Jo","age":17,"role":"owner","tags":["a1"],"code":"a-1"}`,
+			[]JSONSchemaError{
+				{"age", "must be greater than or equal to 18"},
+				{"code", "must match the pattern '^[a-zA-Z0-9]+$'"},
+				{"email", "must be a valid email"},
+				{"name", "must be at least 3 characters long"},
+				{"role", "must be one of [admin member]"},
+				{"tags[0]", "must match the pattern '^[a-zA-Z]+$'"},
+			},
+		},
+		{
+			`{"email":0,"age":""}`,
+			[]JSONSchemaError{{"age", "must be integer"}, {"email", "must be string"}},
+		},
+	}
+
+	for _, t := range tt {
+		var body interface{}
+		s.Nil(json.Unmarshal([]byte(t.body), &body))
+		s.Equal(t.errs, schema.Validate(body))
+	}
+}
+
+func TestJSONSchemaSuite(t *testing.T) {
+	test.Run(t, new(jsonSchemaSuite))
+}
@@ -0,0 +1,419 @@
+package support
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// KVStore is the embedded key-value store backed by bbolt which keeps the
+// data in a single file so that the application can run without any external
+// service, i.e. Redis, for the cache, session store and job queue.
+//
+// Since the file is locked by the process that opens it, the KVStore is
+// shared across the application via OpenKVStore.
+type KVStore struct {
+	db   *bbolt.DB
+	path string
+
+	// ttlBuckets caches the buckets that are already recorded in
+	// kvTTLBucketsBucket to avoid writing them on every Set.
+	ttlBuckets   map[string]bool
+	ttlBucketsMu *sync.Mutex
+}
+
+// kvTTLBucketsBucket records the buckets that are written by Set so that
+// PurgeAllExpired doesn't decode the buckets that are written directly via DB,
+// i.e. the worker's job queues.
+const kvTTLBucketsBucket = "_kvstore:ttl_buckets"
+
+var (
+	kvStores   = map[string]*KVStore{}
+	kvStoresMu = &sync.Mutex{}
+)
+
+// OpenKVStore opens the KVStore at the path or returns the one that is
+// already opened by the current process.
+func OpenKVStore(path string) (*KVStore, error) {
+	kvStoresMu.Lock()
+	defer kvStoresMu.Unlock()
+
+	path = filepath.Clean(path)
+	if store, ok := kvStores[path]; ok {
+		return store, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+
+	// Fail fast instead of blocking forever if another process, i.e. `serve`
+	// and `work`, already holds the file lock.
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	store := &KVStore{
+		db:           db,
+		path:         path,
+		ttlBuckets:   map[string]bool{},
+		ttlBucketsMu: &sync.Mutex{},
+	}
+	kvStores[path] = store
+
+	return store, nil
+}
+
+// Close closes the KVStore which would be re-opened by the next OpenKVStore.
+func (s *KVStore) Close() error {
+	kvStoresMu.Lock()
+	defer kvStoresMu.Unlock()
+
+	delete(kvStores, s.path)
+
+	return s.db.Close()
+}
+
+// DB returns the underlying bbolt database for the atomic operations across
+// multiple buckets.
+func (s *KVStore) DB() *bbolt.DB {
+	return s.db
+}
+
+// Path returns the KVStore's file path.
+func (s *KVStore) Path() string {
+	return s.path
+}
+
+// Get returns the value of the key in the bucket or nil if the key doesn't
+// exist or is already expired.
+func (s *KVStore) Get(bucket, key string) ([]byte, error) {
+	var (
+		value   []byte
+		expired bool
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		value, expired = decodeKVValue(b.Get([]byte(key)))
+		return nil
+	})
+
+	if err != nil || !expired {
+		return value, err
+	}
+
+	// Clean up the expired key lazily as bbolt doesn't support TTL. The key is
+	// checked again within the same transaction as the deletion since it might
+	// be set again after the read above, i.e. a session that is saved.
+	return nil, s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		if _, expired := decodeKVValue(b.Get([]byte(key))); !expired {
+			return nil
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
+// TTL returns the remaining time to live of the key in the bucket which is 0
+// if the key never expires, and false if the key doesn't exist or is already
+// expired.
+func (s *KVStore) TTL(bucket, key string) (time.Duration, bool, error) {
+	var (
+		ttl    time.Duration
+		exists bool
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		data := b.Get([]byte(key))
+		if len(data) < 8 {
+			return nil
+		}
+
+		expiresAt := kvExpiresAt(data)
+		if expiresAt == 0 {
+			exists = true
+			return nil
+		}
+
+		if remaining := time.Until(time.Unix(0, expiresAt)); remaining > 0 {
+			ttl, exists = remaining, true
+		}
+
+		return nil
+	})
+
+	return ttl, exists, err
+}
+
+// Set sets the value of the key in the bucket which expires after the TTL. If
+// the TTL is 0, the key never expires.
+func (s *KVStore) Set(bucket, key string, value []byte, ttl time.Duration) error {
+	return s.update(bucket, func(b *bbolt.Bucket) error {
+		return b.Put([]byte(key), encodeKVValue(value, ttl))
+	})
+}
+
+// SetCapped is the same as Set but keeps at most max unexpired keys in the
+// bucket by deleting the expired keys and then the keys that expire the
+// soonest, i.e. the oldest keys if they are all set with the same TTL. The key
+// that is being set is never evicted. If max is 0, the bucket is uncapped.
+func (s *KVStore) SetCapped(bucket, key string, value []byte, ttl time.Duration, max int) error {
+	return s.update(bucket, func(b *bbolt.Bucket) error {
+		if err := b.Put([]byte(key), encodeKVValue(value, ttl)); err != nil {
+			return err
+		}
+
+		if max <= 0 {
+			return nil
+		}
+
+		type kvEntry struct {
+			key       []byte
+			expiresAt int64
+		}
+
+		evicted := [][]byte{}
+		entries := []kvEntry{}
+		err := b.ForEach(func(k, v []byte) error {
+			if string(k) == key {
+				return nil
+			}
+
+			if _, expired := decodeKVValue(v); expired {
+				evicted = append(evicted, append([]byte{}, k...))
+				return nil
+			}
+
+			expiresAt := kvExpiresAt(v)
+			if expiresAt == 0 {
+				expiresAt = math.MaxInt64
+			}
+
+			entries = append(entries, kvEntry{append([]byte{}, k...), expiresAt})
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > max-1 {
+			sort.SliceStable(entries, func(i, j int) bool {
+				return entries[i].expiresAt < entries[j].expiresAt
+			})
+
+			for _, entry := range entries[:len(entries)-(max-1)] {
+				evicted = append(evicted, entry.key)
+			}
+		}
+
+		for _, k := range evicted {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete deletes the keys in the bucket.
+func (s *KVStore) Delete(bucket string, keys ...string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Keys returns the unexpired keys in the bucket that start with the prefix.
+func (s *KVStore) Keys(bucket, prefix string) ([]string, error) {
+	keys := []string{}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			if _, expired := decodeKVValue(v); !expired {
+				keys = append(keys, string(k))
+			}
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// PurgeAllExpired deletes all the expired keys in the buckets that are ever
+// written by Set or SetCapped.
+func (s *KVStore) PurgeAllExpired() error {
+	buckets := []string{}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(kvTTLBucketsBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			buckets = append(buckets, string(k))
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		if err := s.PurgeExpired(bucket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes all the expired keys in the bucket.
+func (s *KVStore) PurgeExpired(bucket string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		keys := [][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			if _, expired := decodeKVValue(v); expired {
+				keys = append(keys, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// update runs fn with the bucket within a read-write transaction and records
+// the bucket in kvTTLBucketsBucket for PurgeAllExpired.
+func (s *KVStore) update(bucket string, fn func(b *bbolt.Bucket) error) error {
+	s.ttlBucketsMu.Lock()
+	recorded := s.ttlBuckets[bucket]
+	s.ttlBucketsMu.Unlock()
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if !recorded {
+			meta, err := tx.CreateBucketIfNotExists([]byte(kvTTLBucketsBucket))
+			if err != nil {
+				return err
+			}
+
+			if err := meta.Put([]byte(bucket), []byte{}); err != nil {
+				return err
+			}
+		}
+
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		return fn(b)
+	})
+
+	if err == nil && !recorded {
+		s.ttlBucketsMu.Lock()
+		s.ttlBuckets[bucket] = true
+		s.ttlBucketsMu.Unlock()
+	}
+
+	return err
+}
+
+// encodeKVValue prefixes the value with its expiry time in Unix nanoseconds
+// where 0 means that it never expires.
+func encodeKVValue(value []byte, ttl time.Duration) []byte {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expiresAt))
+	copy(data[8:], value)
+
+	return data
+}
+
+// decodeKVValue returns a copy of the value since the data returned by bbolt
+// is only valid within the transaction.
+func decodeKVValue(data []byte) ([]byte, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+
+	expiresAt := kvExpiresAt(data)
+	if expiresAt != 0 && time.Now().UnixNano() >= expiresAt {
+		return nil, true
+	}
+
+	return append([]byte{}, data[8:]...), false
+}
+
+// kvExpiresAt returns the expiry time in Unix nanoseconds that is encoded by
+// encodeKVValue.
+func kvExpiresAt(data []byte) int64 {
+	if len(data) < 8 {
+		return 0
+	}
+
+	return int64(binary.BigEndian.Uint64(data))
+}
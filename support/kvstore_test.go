@@ -0,0 +1,161 @@
+package support
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/test"
+	"go.etcd.io/bbolt"
+)
+
+type kvStoreSuite struct {
+	test.Suite
+	dir   string
+	store *KVStore
+}
+
+func (s *kvStoreSuite) SetupTest() {
+	var err error
+
+	s.dir, err = ioutil.TempDir("", "kvstore")
+	s.Nil(err)
+
+	s.store, err = OpenKVStore(filepath.Join(s.dir, "data", "appy.db"))
+	s.Nil(err)
+}
+
+func (s *kvStoreSuite) TearDownTest() {
+	s.store.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *kvStoreSuite) TestOpenKVStore() {
+	store, err := OpenKVStore(filepath.Join(s.dir, "data", "..", "data", "appy.db"))
+	s.Nil(err)
+	s.Equal(s.store, store)
+	s.Equal(filepath.Join(s.dir, "data", "appy.db"), store.Path())
+}
+
+func (s *kvStoreSuite) TestGetSetDelete() {
+	value, err := s.store.Get("cache", "foo")
+	s.Nil(err)
+	s.Nil(value)
+
+	s.Nil(s.store.Set("cache", "foo", []byte("bar"), 0))
+	s.Nil(s.store.Set("cache", "foobar", []byte("baz"), time.Hour))
+	s.Nil(s.store.Set("cache", "expired", []byte("baz"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	value, err = s.store.Get("cache", "foo")
+	s.Nil(err)
+	s.Equal([]byte("bar"), value)
+
+	value, err = s.store.Get("cache", "expired")
+	s.Nil(err)
+	s.Nil(value)
+
+	ttl, exists, err := s.store.TTL("cache", "foo")
+	s.Nil(err)
+	s.True(exists)
+	s.Equal(time.Duration(0), ttl)
+
+	ttl, exists, err = s.store.TTL("cache", "foobar")
+	s.Nil(err)
+	s.True(exists)
+	s.True(ttl > 59*time.Minute && ttl <= time.Hour)
+
+	_, exists, err = s.store.TTL("cache", "expired")
+	s.Nil(err)
+	s.False(exists)
+
+	keys, err := s.store.Keys("cache", "foo")
+	s.Nil(err)
+	s.Equal([]string{"foo", "foobar"}, keys)
+
+	s.Nil(s.store.Delete("cache", "foo", "foobar"))
+	keys, err = s.store.Keys("cache", "")
+	s.Nil(err)
+	s.Equal([]string{}, keys)
+}
+
+func (s *kvStoreSuite) TestPurgeExpired() {
+	s.Nil(s.store.Set("cache", "foo", []byte("bar"), 0))
+	s.Nil(s.store.Set("cache", "expired", []byte("baz"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	s.Nil(s.store.PurgeExpired("cache"))
+	s.Nil(s.store.PurgeExpired("unknown"))
+
+	count := 0
+	s.Nil(s.store.DB().View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket([]byte("cache")).Stats().KeyN
+		return nil
+	}))
+	s.Equal(1, count)
+}
+
+func (s *kvStoreSuite) TestSetCapped() {
+	s.Nil(s.store.Set("capped", "expired", []byte("0"), time.Nanosecond))
+	s.Nil(s.store.Set("capped", "forever", []byte("0"), 0))
+	time.Sleep(time.Millisecond)
+
+	s.Nil(s.store.SetCapped("capped", "a", []byte("1"), time.Hour, 3))
+	s.Nil(s.store.SetCapped("capped", "b", []byte("2"), time.Hour, 3))
+
+	keys, err := s.store.Keys("capped", "")
+	s.Nil(err)
+	s.Equal([]string{"a", "b", "forever"}, keys)
+
+	s.Nil(s.store.SetCapped("capped", "c", []byte("3"), time.Hour, 3))
+	keys, err = s.store.Keys("capped", "")
+	s.Nil(err)
+	s.Equal([]string{"b", "c", "forever"}, keys)
+
+	s.Nil(s.store.SetCapped("capped", "b", []byte("4"), time.Hour, 1))
+	keys, err = s.store.Keys("capped", "")
+	s.Nil(err)
+	s.Equal([]string{"b"}, keys)
+
+	value, err := s.store.Get("capped", "b")
+	s.Nil(err)
+	s.Equal([]byte("4"), value)
+
+	s.Nil(s.store.SetCapped("capped", "d", []byte("5"), time.Hour, 0))
+	keys, err = s.store.Keys("capped", "")
+	s.Nil(err)
+	s.Equal([]string{"b", "d"}, keys)
+}
+
+func (s *kvStoreSuite) TestPurgeAllExpired() {
+	s.Nil(s.store.Set("cache", "foo", []byte("bar"), 0))
+	s.Nil(s.store.Set("cache", "expired", []byte("baz"), time.Nanosecond))
+	s.Nil(s.store.SetCapped("apq", "expired", []byte("baz"), time.Nanosecond, 10))
+	s.Nil(s.store.DB().Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("raw"))
+		if err != nil {
+			return err
+		}
+
+		// The raw value would be decoded as already expired if the bucket
+		// were purged.
+		return b.Put([]byte("job"), []byte{0, 0, 0, 0, 0, 0, 0, 1})
+	}))
+	time.Sleep(time.Millisecond)
+	s.Nil(s.store.PurgeAllExpired())
+
+	counts := map[string]int{}
+	s.Nil(s.store.DB().View(func(tx *bbolt.Tx) error {
+		for _, bucket := range []string{"cache", "apq", "raw"} {
+			counts[bucket] = tx.Bucket([]byte(bucket)).Stats().KeyN
+		}
+
+		return nil
+	}))
+	s.Equal(map[string]int{"cache": 1, "apq": 0, "raw": 1}, counts)
+}
+
+func TestKVStoreSuite(t *testing.T) {
+	test.Run(t, new(kvStoreSuite))
+}
@@ -0,0 +1,187 @@
+package support
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LifecycleEvent indicates the application lifecycle stage.
+type LifecycleEvent string
+
+const (
+	// LifecycleEventBoot is emitted when the application is ready to serve the
+	// HTTP requests.
+	LifecycleEventBoot LifecycleEvent = "app.boot"
+
+	// LifecycleEventMaintenanceEnter is emitted when the application enters
+	// the maintenance mode.
+	LifecycleEventMaintenanceEnter LifecycleEvent = "app.maintenance.enter"
+
+	// LifecycleEventMaintenanceExit is emitted when the application exits the
+	// maintenance mode.
+	LifecycleEventMaintenanceExit LifecycleEvent = "app.maintenance.exit"
+
+	// LifecycleEventShutdown is emitted when the application starts shutting
+	// down gracefully.
+	LifecycleEventShutdown LifecycleEvent = "app.shutdown"
+
+	// LifecycleEventMigrate is emitted when the database migrations are
+	// completed.
+	LifecycleEventMigrate LifecycleEvent = "db.migrate"
+)
+
+type (
+	// Lifecycle emits the application lifecycle events to the in-process
+	// listeners and the webhooks configured via LIFECYCLE_WEBHOOK_URLS.
+	Lifecycle struct {
+		client    *http.Client
+		config    *Config
+		listeners map[LifecycleEvent][]LifecycleListener
+		logger    *Logger
+		mu        sync.RWMutex
+	}
+
+	// LifecycleListener is called when the event that it listens to is emitted.
+	LifecycleListener func(payload *LifecyclePayload)
+
+	// LifecyclePayload is the JSON payload that is sent to the webhooks.
+	LifecyclePayload struct {
+		Event     LifecycleEvent `json:"event"`
+		Env       string         `json:"env"`
+		Build     string         `json:"build"`
+		Hostname  string         `json:"hostname"`
+		PID       int            `json:"pid"`
+		Timestamp time.Time      `json:"timestamp"`
+		Data      H              `json:"data"`
+	}
+)
+
+// NewLifecycle initializes the Lifecycle instance.
+func NewLifecycle(config *Config, logger *Logger) *Lifecycle {
+	return &Lifecycle{
+		client:    &http.Client{Timeout: config.LifecycleWebhookTimeout},
+		config:    config,
+		listeners: map[LifecycleEvent][]LifecycleListener{},
+		logger:    logger,
+	}
+}
+
+// On registers an in-process listener for the lifecycle event.
+func (l *Lifecycle) On(event LifecycleEvent, listener LifecycleListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.listeners[event] = append(l.listeners[event], listener)
+}
+
+// Emit notifies the listeners and sends the webhooks for the lifecycle event.
+// The webhooks are sent concurrently and Emit waits for all of them so that
+// the shutdown event is delivered before the process exits. Any webhook
+// failure is logged without interrupting the application.
+func (l *Lifecycle) Emit(event LifecycleEvent, data H) {
+	l.EmitContext(context.Background(), event, data)
+}
+
+// EmitContext is the same as Emit but the webhooks are also cancelled when
+// the context is done, i.e. to bound the total time that the shutdown event
+// takes within HTTP_GRACEFUL_SHUTDOWN_TIMEOUT.
+func (l *Lifecycle) EmitContext(ctx context.Context, event LifecycleEvent, data H) {
+	if data == nil {
+		data = H{}
+	}
+
+	hostname, _ := os.Hostname()
+	payload := &LifecyclePayload{
+		Event:     event,
+		Env:       l.config.AppyEnv,
+		Build:     Build,
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}
+
+	l.mu.RLock()
+	listeners := l.listeners[event]
+	l.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(payload)
+	}
+
+	if len(l.config.LifecycleWebhookURLs) < 1 || !l.isWebhookEvent(event) {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+
+	// All the webhooks share the same deadline so that N slow webhooks don't
+	// take N times LIFECYCLE_WEBHOOK_TIMEOUT.
+	ctx, cancel := context.WithTimeout(ctx, l.config.LifecycleWebhookTimeout)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	for _, url := range l.config.LifecycleWebhookURLs {
+		wg.Add(1)
+
+		go func(url string) {
+			defer wg.Done()
+
+			if err := l.sendWebhook(ctx, url, event, body); err != nil {
+				l.logger.Errorf("[LIFECYCLE] failed to send '%s' webhook to '%s': %s", event, url, err)
+			}
+		}(url)
+	}
+
+	wg.Wait()
+}
+
+func (l *Lifecycle) isWebhookEvent(event LifecycleEvent) bool {
+	if len(l.config.LifecycleWebhookEvents) < 1 {
+		return true
+	}
+
+	return ArrayContains(l.config.LifecycleWebhookEvents, string(event))
+}
+
+func (l *Lifecycle) sendWebhook(ctx context.Context, url string, event LifecycleEvent, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "appy/"+VERSION)
+	req.Header.Set("X-Appy-Event", string(event))
+
+	if len(l.config.LifecycleWebhookSecret) > 0 {
+		mac := hmac.New(sha256.New, l.config.LifecycleWebhookSecret)
+		mac.Write(body)
+		req.Header.Set("X-Appy-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package support
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/test"
+)
+
+type lifecycleSuite struct {
+	test.Suite
+	asset  *Asset
+	config *Config
+	logger *Logger
+	buffer *bytes.Buffer
+	writer *bufio.Writer
+}
+
+func (s *lifecycleSuite) SetupTest() {
+	os.Setenv("APPY_ENV", "development")
+	os.Setenv("APPY_MASTER_KEY", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	s.logger, s.buffer, s.writer = NewTestLogger()
+	s.asset = NewAsset(nil, "")
+	s.config = NewConfig(s.asset, s.logger)
+}
+
+func (s *lifecycleSuite) TearDownTest() {
+	os.Unsetenv("APPY_ENV")
+	os.Unsetenv("APPY_MASTER_KEY")
+}
+
+func (s *lifecycleSuite) TestListeners() {
+	lifecycle := NewLifecycle(s.config, s.logger)
+	payloads := []*LifecyclePayload{}
+	lifecycle.On(LifecycleEventBoot, func(payload *LifecyclePayload) {
+		payloads = append(payloads, payload)
+	})
+
+	lifecycle.Emit(LifecycleEventShutdown, nil)
+	s.Equal(0, len(payloads))
+
+	lifecycle.Emit(LifecycleEventBoot, H{"foo": "bar"})
+	s.Equal(1, len(payloads))
+	s.Equal(LifecycleEventBoot, payloads[0].Event)
+	s.Equal("development", payloads[0].Env)
+	s.Equal(os.Getpid(), payloads[0].PID)
+	s.Equal(H{"foo": "bar"}, payloads[0].Data)
+}
+
+func (s *lifecycleSuite) TestWebhooks() {
+	requests := []*http.Request{}
+	bodies := [][]byte{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+	}))
+	defer ts.Close()
+
+	s.config.LifecycleWebhookURLs = []string{ts.URL}
+	s.config.LifecycleWebhookEvents = []string{"app.boot", "db.migrate"}
+	s.config.LifecycleWebhookSecret = []byte("secret")
+	lifecycle := NewLifecycle(s.config, s.logger)
+
+	lifecycle.Emit(LifecycleEventShutdown, nil)
+	s.Equal(0, len(requests))
+
+	lifecycle.Emit(LifecycleEventMigrate, H{"databases": []string{"primary"}})
+	s.Equal(1, len(requests))
+	s.Equal("POST", requests[0].Method)
+	s.Equal("application/json", requests[0].Header.Get("Content-Type"))
+	s.Equal("db.migrate", requests[0].Header.Get("X-Appy-Event"))
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(bodies[0])
+	s.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), requests[0].Header.Get("X-Appy-Signature"))
+
+	payload := map[string]interface{}{}
+	s.Nil(json.Unmarshal(bodies[0], &payload))
+	s.Equal("db.migrate", payload["event"])
+	s.Equal(map[string]interface{}{"databases": []interface{}{"primary"}}, payload["data"])
+}
+
+func (s *lifecycleSuite) TestWebhookFailure() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	s.config.LifecycleWebhookURLs = []string{ts.URL}
+	lifecycle := NewLifecycle(s.config, s.logger)
+	lifecycle.Emit(LifecycleEventBoot, nil)
+	s.writer.Flush()
+
+	s.Contains(s.buffer.String(), "failed to send 'app.boot' webhook")
+	s.Contains(s.buffer.String(), "unexpected status code 500")
+}
+
+func (s *lifecycleSuite) TestWebhooksShareDeadline() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	s.config.LifecycleWebhookURLs = []string{ts.URL + "/1", ts.URL + "/2", ts.URL + "/3"}
+	s.config.LifecycleWebhookTimeout = 100 * time.Millisecond
+	lifecycle := NewLifecycle(s.config, s.logger)
+
+	start := time.Now()
+	lifecycle.Emit(LifecycleEventShutdown, nil)
+	s.Less(int64(time.Since(start)), int64(250*time.Millisecond))
+
+	s.config.LifecycleWebhookTimeout = time.Minute
+	lifecycle = NewLifecycle(s.config, s.logger)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	lifecycle.EmitContext(ctx, LifecycleEventShutdown, nil)
+	s.Less(int64(time.Since(start)), int64(250*time.Millisecond))
+	s.writer.Flush()
+	s.Contains(s.buffer.String(), "failed to send 'app.shutdown' webhook to '"+ts.URL+"/3'")
+}
+
+func TestLifecycleSuite(t *testing.T) {
+	test.Run(t, new(lifecycleSuite))
+}
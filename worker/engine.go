@@ -3,9 +3,12 @@ package worker
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/appist/appy/mock"
@@ -25,8 +28,10 @@ type Engine struct {
 	config    *support.Config
 	dbManager *record.Engine
 	jobs      []*Job
+	jobTypes  []string
 	logger    *support.Logger
 	mu        *sync.Mutex
+	queue     *kvQueue
 }
 
 // Handler processes background jobs.
@@ -91,8 +96,10 @@ func NewEngine(asset *support.Asset, config *support.Config, dbManager *record.E
 		config,
 		dbManager,
 		[]*Job{},
+		[]string{},
 		l,
 		&sync.Mutex{},
+		nil,
 	}
 
 	if len(config.WorkerRedisSentinelAddrs) > 0 {
@@ -113,11 +120,17 @@ func NewEngine(asset *support.Asset, config *support.Config, dbManager *record.E
 			config,
 			dbManager,
 			[]*Job{},
+			[]string{},
 			l,
 			&sync.Mutex{},
+			nil,
 		}
 	}
 
+	if config.WorkerProvider == "kv" {
+		worker.queue = newKVQueue(config, l)
+	}
+
 	workerLogger.worker = worker
 	worker.ServeMux.Use(func(next Handler) Handler {
 		return HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
@@ -162,9 +175,35 @@ func (w *Engine) Enqueue(job *Job, opts *JobOptions) (*JobResult, error) {
 		return nil, nil
 	}
 
+	if w.queue != nil {
+		return w.queue.enqueue(job, opts)
+	}
+
 	return w.Client.Enqueue(job, parseJobOptions(opts)...)
 }
 
+// Handle registers the handler for the job type.
+func (w *Engine) Handle(jobType string, handler Handler) {
+	w.mu.Lock()
+	w.jobTypes = append(w.jobTypes, jobType)
+	w.mu.Unlock()
+
+	w.ServeMux.Handle(jobType, handler)
+}
+
+// HandleFunc registers the handler function for the job type.
+func (w *Engine) HandleFunc(jobType string, handler func(context.Context, *Job) error) {
+	w.Handle(jobType, HandlerFunc(handler))
+}
+
+// JobTypes returns the job types that are registered via Handle/HandleFunc.
+func (w *Engine) JobTypes() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]string{}, w.jobTypes...)
+}
+
 // Jobs returns the enqueued jobs, only available for unit test with
 // APPY_ENV=test.
 func (w *Engine) Jobs() []*Job {
@@ -194,19 +233,59 @@ func (w *Engine) Info() []string {
 		),
 	)
 
+	concurrency, priorities := w.config.WorkerConcurrency, w.config.WorkerQueues
+	if w.queue != nil {
+		concurrency, priorities = w.queue.concurrency(), w.queue.queues()
+	}
+
 	queues := []string{}
-	for key, value := range w.config.WorkerQueues {
+	for key, value := range priorities {
 		queues = append(queues, fmt.Sprintf("%s=%d", key, value))
 	}
 
 	lines = append(lines, w.dbManager.Info())
-	lines = append(lines, fmt.Sprintf("* Concurrency: %d, queues: %s", w.config.WorkerConcurrency, strings.Join(queues, ", ")))
+	lines = append(lines, fmt.Sprintf("* Concurrency: %d, queues: %s", concurrency, strings.Join(queues, ", ")))
 	return append(lines, "* Worker is now ready to process jobs...")
 }
 
-// Run starts running the worker to process background jobs.
+// Run starts running the worker to process background jobs until it
+// receives SIGTERM/SIGINT.
 func (w *Engine) Run() {
-	w.Server.Run(w.ServeMux)
+	if w.queue == nil {
+		w.Server.Run(w.ServeMux)
+		return
+	}
+
+	if err := w.Start(); err != nil {
+		w.logger.Fatal(err)
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	w.Stop()
+}
+
+// Start starts processing the background jobs without blocking, i.e. to
+// process the jobs within the `serve` command with WORKER_PROVIDER=kv.
+func (w *Engine) Start() error {
+	if w.queue == nil {
+		return w.Server.Start(w.ServeMux)
+	}
+
+	return w.queue.start(w.ServeMux)
+}
+
+// Stop stops processing the background jobs and waits for the active jobs to
+// finish within WORKER_GRACEFUL_SHUTDOWN_TIMEOUT.
+func (w *Engine) Stop() {
+	if w.queue == nil {
+		w.Server.Stop()
+		return
+	}
+
+	w.queue.stop()
 }
 
 // MockedHandler is used for mocking in unit test.
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -109,6 +111,24 @@ func (s *engineSuite) TestGlobalMiddleware() {
 	s.Equal(10, count)
 }
 
+func (s *engineSuite) TestHandle() {
+	processed := []string{}
+	worker := NewEngine(s.asset, s.config, s.dbManager, s.logger)
+	worker.Handle("foo", HandlerFunc(func(ctx context.Context, job *Job) error {
+		processed = append(processed, job.Type)
+		return nil
+	}))
+	worker.HandleFunc("bar", func(ctx context.Context, job *Job) error {
+		processed = append(processed, job.Type)
+		return nil
+	})
+
+	worker.ProcessTask(context.Background(), NewJob("foo", map[string]interface{}{}))
+	worker.ProcessTask(context.Background(), NewJob("bar", map[string]interface{}{}))
+	s.Equal([]string{"foo", "bar"}, processed)
+	s.Equal([]string{"foo", "bar"}, worker.JobTypes())
+}
+
 func (s *engineSuite) TestEnqueue() {
 	s.config = support.NewConfig(s.asset, s.logger)
 	worker := NewEngine(s.asset, s.config, s.dbManager, s.logger)
@@ -123,6 +143,52 @@ func (s *engineSuite) TestEnqueue() {
 	s.Nil(err)
 }
 
+func (s *engineSuite) TestKVProvider() {
+	dir, err := ioutil.TempDir("", "worker")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	s.config.WorkerProvider = "kv"
+	s.config.KVStorePath = filepath.Join(dir, "appy.db")
+	worker := NewEngine(s.asset, s.config, s.dbManager, s.logger)
+
+	processed := make(chan string, 1)
+	worker.HandleFunc("foo", func(ctx context.Context, job *Job) error {
+		name, err := job.Payload.GetString("name")
+		if err != nil {
+			return err
+		}
+
+		processed <- name
+		return nil
+	})
+
+	result, err := worker.Enqueue(NewJob("foo", map[string]interface{}{"name": "barfoo"}), &JobOptions{UniqueTTL: time.Minute})
+	s.Nil(err)
+	s.Equal("default", result.Queue)
+
+	_, err = worker.Enqueue(NewJob("foo", map[string]interface{}{"name": "barfoo"}), &JobOptions{UniqueTTL: time.Minute})
+	s.Equal(asynq.ErrDuplicateTask, err)
+
+	s.Nil(worker.Start())
+	defer worker.Stop()
+	s.NotNil(worker.Start())
+
+	select {
+	case name := <-processed:
+		s.Equal("barfoo", name)
+	case <-time.After(5 * time.Second):
+		s.Fail("the job isn't processed")
+	}
+
+	// Stop the worker before closing the store that it shares.
+	worker.Stop()
+
+	store, err := support.OpenKVStore(s.config.KVStorePath)
+	s.Nil(err)
+	s.Nil(store.Close())
+}
+
 func (s *engineSuite) TestOpsWithTestEnv() {
 	os.Setenv("APPY_ENV", "test")
 	defer os.Unsetenv("APPY_ENV")
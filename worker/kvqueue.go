@@ -0,0 +1,555 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/appist/appy/support"
+	"github.com/gofrs/uuid"
+	"github.com/hibiken/asynq"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	kvQueueActiveBucket    = "jobs:active"
+	kvQueueDeadBucket      = "jobs:dead"
+	kvQueueScheduledBucket = "jobs:scheduled"
+	kvQueueUniqueBucket    = "jobs:unique"
+
+	kvQueueDefaultMaxRetry = 25
+	kvQueueDefaultTimeout  = 30 * time.Minute
+	kvQueuePollInterval    = time.Second
+)
+
+type (
+	// kvQueue processes the background jobs that are stored in the embedded
+	// key-value store. Since the key-value store is locked by a single
+	// process, the jobs are only enqueued/processed within the same process.
+	kvQueue struct {
+		config     *support.Config
+		logger     *support.Logger
+		mu         *sync.Mutex
+		cancelPoll context.CancelFunc
+		cancelJobs context.CancelFunc
+		stopped    chan struct{}
+		wg         *sync.WaitGroup
+	}
+
+	kvJobEntry struct {
+		key string
+		job *kvJob
+	}
+
+	kvJob struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		Payload    json.RawMessage `json:"payload"`
+		Queue      string          `json:"queue"`
+		MaxRetry   int             `json:"maxRetry"`
+		Retried    int             `json:"retried"`
+		Timeout    time.Duration   `json:"timeout"`
+		Deadline   time.Time       `json:"deadline"`
+		EnqueuedAt time.Time       `json:"enqueuedAt"`
+		ProcessAt  time.Time       `json:"processAt"`
+		ErrorMsg   string          `json:"errorMsg,omitempty"`
+	}
+)
+
+func newKVQueue(config *support.Config, logger *support.Logger) *kvQueue {
+	return &kvQueue{
+		config: config,
+		logger: logger,
+		mu:     &sync.Mutex{},
+		wg:     &sync.WaitGroup{},
+	}
+}
+
+// store opens the key-value store lazily so that the commands which don't
+// enqueue/process the jobs, i.e. `db:migrate`, don't hold the file lock.
+func (q *kvQueue) store() (*support.KVStore, error) {
+	return support.OpenKVStore(q.config.KVStorePath)
+}
+
+func (q *kvQueue) enqueue(job *Job, opts *JobOptions) (*JobResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	store, err := q.store()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &JobOptions{}
+	}
+
+	id, _ := uuid.NewV4()
+	now := time.Now().UTC()
+	j := &kvJob{
+		ID:         id.String(),
+		Type:       job.Type,
+		Payload:    payload,
+		Queue:      opts.Queue,
+		MaxRetry:   opts.MaxRetry,
+		Timeout:    opts.Timeout,
+		Deadline:   opts.Deadline,
+		EnqueuedAt: now,
+		ProcessAt:  now,
+	}
+
+	if j.Queue == "" {
+		j.Queue = "default"
+	}
+
+	if j.MaxRetry == 0 {
+		j.MaxRetry = kvQueueDefaultMaxRetry
+	}
+
+	if j.Timeout == 0 && j.Deadline.IsZero() {
+		j.Timeout = kvQueueDefaultTimeout
+	}
+
+	if !opts.ProcessAt.IsZero() {
+		j.ProcessAt = opts.ProcessAt.UTC()
+	}
+
+	if opts.ProcessIn != 0 {
+		j.ProcessAt = now.Add(opts.ProcessIn)
+	}
+
+	if opts.UniqueTTL != 0 {
+		// The check-and-set is safe with the mutex as the key-value store can
+		// only be opened by the current process.
+		uniqueKey := fmt.Sprintf("%s:%s:%s", j.Queue, j.Type, j.Payload)
+
+		existing, err := store.Get(kvQueueUniqueBucket, uniqueKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return nil, asynq.ErrDuplicateTask
+		}
+
+		if err := store.Set(kvQueueUniqueBucket, uniqueKey, []byte(j.ID), opts.UniqueTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	err = store.DB().Update(func(tx *bbolt.Tx) error {
+		return putKVJob(tx, kvQueueScheduledBucket, j.scheduledKey(), j)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := j.Deadline
+	if deadline.IsZero() {
+		deadline = time.Unix(0, 0)
+	}
+
+	return &JobResult{
+		ID:         j.ID,
+		EnqueuedAt: j.EnqueuedAt,
+		ProcessAt:  j.ProcessAt,
+		Retry:      j.MaxRetry,
+		Queue:      j.Queue,
+		Timeout:    j.Timeout,
+		Deadline:   deadline,
+	}, nil
+}
+
+// concurrency returns WORKER_CONCURRENCY or the number of CPUs if it isn't
+// positive which is the same as asynq.
+func (q *kvQueue) concurrency() int {
+	if q.config.WorkerConcurrency <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return q.config.WorkerConcurrency
+}
+
+// queues returns the WORKER_QUEUES with the positive priorities or only the
+// "default" queue if there is none which is the same as asynq.
+func (q *kvQueue) queues() map[string]int {
+	queues := map[string]int{}
+	for name, priority := range q.config.WorkerQueues {
+		if priority > 0 {
+			queues[name] = priority
+		}
+	}
+
+	if len(queues) == 0 {
+		queues["default"] = 1
+	}
+
+	return queues
+}
+
+// start starts polling the due jobs and processing them with up to
+// WORKER_CONCURRENCY goroutines.
+func (q *kvQueue) start(handler Handler) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.cancelPoll != nil {
+		return fmt.Errorf("the worker is already running")
+	}
+
+	store, err := q.store()
+	if err != nil {
+		return err
+	}
+
+	// Re-schedule the jobs that were being processed when the process exited
+	// unexpectedly.
+	err = store.DB().Update(func(tx *bbolt.Tx) error {
+		jobs, err := getKVJobs(tx, kvQueueActiveBucket, 0, time.Time{})
+		if err != nil {
+			return err
+		}
+
+		for key, j := range jobs {
+			if err := deleteKVJob(tx, kvQueueActiveBucket, key); err != nil {
+				return err
+			}
+
+			if err := putKVJob(tx, kvQueueScheduledBucket, j.scheduledKey(), j); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The jobs are only cancelled if they can't finish within the graceful
+	// shutdown timeout after the polling stops.
+	pollCtx, cancelPoll := context.WithCancel(context.Background())
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	q.cancelPoll = cancelPoll
+	q.cancelJobs = cancelJobs
+	q.stopped = make(chan struct{})
+	concurrency := q.concurrency()
+	slots := make(chan struct{}, concurrency)
+
+	go func() {
+		defer close(q.stopped)
+
+		ticker := time.NewTicker(kvQueuePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			jobs, err := q.dequeue(store, concurrency-len(slots))
+			if err != nil {
+				q.logger.Errorf("[WORKER] unable to dequeue the jobs: %s", err)
+				continue
+			}
+
+			for _, j := range jobs {
+				slots <- struct{}{}
+				q.wg.Add(1)
+
+				go func(j *kvJob) {
+					defer func() {
+						<-slots
+						q.wg.Done()
+					}()
+
+					q.process(jobCtx, store, handler, j)
+				}(j)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop stops polling the jobs and waits for the active jobs to finish within
+// WORKER_GRACEFUL_SHUTDOWN_TIMEOUT. The unfinished jobs are re-scheduled on
+// the next start.
+func (q *kvQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.cancelPoll == nil {
+		return
+	}
+
+	q.cancelPoll()
+	<-q.stopped
+	q.cancelPoll = nil
+	defer q.cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(q.config.WorkerGracefulShutdownTimeout):
+		q.logger.Warnf("[WORKER] unable to finish the active jobs within %s", q.config.WorkerGracefulShutdownTimeout)
+	}
+}
+
+// dequeue moves up to n due jobs in WORKER_QUEUES from the scheduled bucket
+// into the active bucket. The jobs in the other queues are left scheduled
+// until the worker is configured to process them.
+func (q *kvQueue) dequeue(store *support.KVStore, n int) ([]*kvJob, error) {
+	dequeued := []*kvJob{}
+	if n <= 0 {
+		return dequeued, nil
+	}
+
+	queues := q.queues()
+	err := store.DB().Update(func(tx *bbolt.Tx) error {
+		due, err := getDueKVJobs(tx, kvQueueScheduledBucket, queues, n, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range q.pick(queues, due, n) {
+			if err := deleteKVJob(tx, kvQueueScheduledBucket, entry.key); err != nil {
+				return err
+			}
+
+			if err := putKVJob(tx, kvQueueActiveBucket, entry.job.ID, entry.job); err != nil {
+				return err
+			}
+
+			dequeued = append(dequeued, entry.job)
+		}
+
+		return nil
+	})
+
+	return dequeued, err
+}
+
+// pick picks up to n jobs from the due jobs of each queue where the queue is
+// chosen by the priority, i.e. always the highest priority one with
+// WORKER_STRICT_PRIORITY or randomly weighted by the priority otherwise.
+func (q *kvQueue) pick(queues map[string]int, due map[string][]kvJobEntry, n int) []kvJobEntry {
+	picked := []kvJobEntry{}
+
+	for len(picked) < n && len(due) > 0 {
+		names := []string{}
+		total := 0
+		for name := range due {
+			names = append(names, name)
+			total += queues[name]
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			if queues[names[i]] != queues[names[j]] {
+				return queues[names[i]] > queues[names[j]]
+			}
+
+			return names[i] < names[j]
+		})
+
+		name := names[0]
+		if !q.config.WorkerStrictPriority {
+			weight := rand.Intn(total)
+			for _, candidate := range names {
+				if weight < queues[candidate] {
+					name = candidate
+					break
+				}
+
+				weight -= queues[candidate]
+			}
+		}
+
+		picked = append(picked, due[name][0])
+		due[name] = due[name][1:]
+		if len(due[name]) == 0 {
+			delete(due, name)
+		}
+	}
+
+	return picked
+}
+
+func (q *kvQueue) process(ctx context.Context, store *support.KVStore, handler Handler, j *kvJob) {
+	var data map[string]interface{}
+	jobErr := json.Unmarshal(j.Payload, &data)
+
+	if jobErr == nil {
+		jobErr = q.run(ctx, handler, asynq.NewTask(j.Type, data), j)
+	}
+
+	// Leave the job in the active bucket to be re-scheduled on the next
+	// start if it is interrupted by the shutdown.
+	if ctx.Err() != nil {
+		return
+	}
+
+	err := store.DB().Update(func(tx *bbolt.Tx) error {
+		if err := deleteKVJob(tx, kvQueueActiveBucket, j.ID); err != nil {
+			return err
+		}
+
+		if jobErr == nil {
+			return nil
+		}
+
+		q.logger.Errorf("[WORKER] job: %s, payload: (%s) failed: %s", j.Type, j.Payload, jobErr)
+		j.ErrorMsg = jobErr.Error()
+		j.Retried++
+
+		if j.Retried > j.MaxRetry {
+			return putKVJob(tx, kvQueueDeadBucket, j.ID, j)
+		}
+
+		j.ProcessAt = time.Now().UTC().Add(kvQueueRetryDelay(j.Retried))
+		return putKVJob(tx, kvQueueScheduledBucket, j.scheduledKey(), j)
+	})
+
+	if err != nil {
+		q.logger.Errorf("[WORKER] unable to update the job '%s': %s", j.ID, err)
+	}
+}
+
+// run runs the handler with the job's timeout/deadline and recovers the panic
+// as an error so that the job can be retried.
+func (q *kvQueue) run(ctx context.Context, handler Handler, task *Job, j *kvJob) (err error) {
+	if j.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	if !j.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, j.Deadline)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler.ProcessTask(ctx, task)
+}
+
+// scheduledKey orders the scheduled jobs by the process time so that the due
+// jobs can be found by iterating from the beginning of the bucket.
+func (j *kvJob) scheduledKey() string {
+	return fmt.Sprintf("%020d:%s", j.ProcessAt.UnixNano(), j.ID)
+}
+
+// kvQueueRetryDelay returns the exponential backoff delay which is the same
+// as the default one in asynq without the randomness.
+func kvQueueRetryDelay(retried int) time.Duration {
+	return time.Duration(math.Pow(float64(retried), 4)+15) * time.Second
+}
+
+func putKVJob(tx *bbolt.Tx, bucket, key string, j *kvJob) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(key), data)
+}
+
+func deleteKVJob(tx *bbolt.Tx, bucket, key string) error {
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+
+	return b.Delete([]byte(key))
+}
+
+// getDueKVJobs returns up to n jobs per queue that are due before the time in
+// the bucket which is ordered by the process time, only for the queues.
+func getDueKVJobs(tx *bbolt.Tx, bucket string, queues map[string]int, n int, before time.Time) (map[string][]kvJobEntry, error) {
+	due := map[string][]kvJobEntry{}
+
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return due, nil
+	}
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		j := &kvJob{}
+		if err := json.Unmarshal(v, j); err != nil {
+			return nil, err
+		}
+
+		if j.ProcessAt.After(before) {
+			break
+		}
+
+		if _, ok := queues[j.Queue]; !ok || len(due[j.Queue]) >= n {
+			continue
+		}
+
+		due[j.Queue] = append(due[j.Queue], kvJobEntry{string(k), j})
+	}
+
+	return due, nil
+}
+
+// getKVJobs returns up to n jobs, or all if n is 0, in the bucket that are due
+// before the time, or all if the time is zero.
+func getKVJobs(tx *bbolt.Tx, bucket string, n int, before time.Time) (map[string]*kvJob, error) {
+	jobs := map[string]*kvJob{}
+
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return jobs, nil
+	}
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if n > 0 && len(jobs) >= n {
+			break
+		}
+
+		j := &kvJob{}
+		if err := json.Unmarshal(v, j); err != nil {
+			return nil, err
+		}
+
+		if !before.IsZero() && j.ProcessAt.After(before) {
+			break
+		}
+
+		jobs[string(k)] = j
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,373 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/appist/appy/support"
+	"github.com/appist/appy/test"
+	"go.etcd.io/bbolt"
+)
+
+type kvQueueSuite struct {
+	test.Suite
+	config *support.Config
+	logger *support.Logger
+	dir    string
+	queue  *kvQueue
+	store  *support.KVStore
+}
+
+func (s *kvQueueSuite) SetupTest() {
+	os.Setenv("APPY_ENV", "development")
+	os.Setenv("APPY_MASTER_KEY", "58f364f29b568807ab9cffa22c99b538")
+	os.Setenv("HTTP_CSRF_SECRET", "481e5d98a31585148b8b1dfb6a3c0465")
+	os.Setenv("HTTP_SESSION_SECRETS", "481e5d98a31585148b8b1dfb6a3c0465")
+
+	var err error
+	s.dir, err = ioutil.TempDir("", "kvqueue")
+	s.Nil(err)
+
+	s.logger, _, _ = support.NewTestLogger()
+	s.config = support.NewConfig(support.NewAsset(nil, ""), s.logger)
+	s.config.WorkerProvider = "kv"
+	s.config.KVStorePath = filepath.Join(s.dir, "appy.db")
+	s.queue = newKVQueue(s.config, s.logger)
+
+	s.store, err = s.queue.store()
+	s.Nil(err)
+}
+
+func (s *kvQueueSuite) TearDownTest() {
+	s.queue.stop()
+	s.Nil(s.store.Close())
+	os.RemoveAll(s.dir)
+
+	os.Unsetenv("APPY_ENV")
+	os.Unsetenv("APPY_MASTER_KEY")
+	os.Unsetenv("HTTP_CSRF_SECRET")
+	os.Unsetenv("HTTP_SESSION_SECRETS")
+}
+
+func (s *kvQueueSuite) enqueue(queue, name string, opts *JobOptions) *JobResult {
+	if opts == nil {
+		opts = &JobOptions{}
+	}
+	opts.Queue = queue
+
+	result, err := s.queue.enqueue(NewJob("foo", map[string]interface{}{"name": name}), opts)
+	s.Nil(err)
+
+	return result
+}
+
+func (s *kvQueueSuite) jobs(bucket string) map[string]*kvJob {
+	var jobs map[string]*kvJob
+
+	s.Nil(s.store.DB().View(func(tx *bbolt.Tx) error {
+		jobs = s.jobsInTx(tx, bucket)
+		return nil
+	}))
+
+	return jobs
+}
+
+func (s *kvQueueSuite) jobsInTx(tx *bbolt.Tx, bucket string) map[string]*kvJob {
+	jobs, err := getKVJobs(tx, bucket, 0, time.Time{})
+	s.Nil(err)
+
+	return jobs
+}
+
+func (s *kvQueueSuite) TestConcurrencyAndQueues() {
+	tt := []struct {
+		concurrency         int
+		queues              map[string]int
+		expectedConcurrency int
+		expectedQueues      map[string]int
+	}{
+		{25, map[string]int{"default": 10}, 25, map[string]int{"default": 10}},
+		{0, map[string]int{"critical": 6, "low": 1}, runtime.NumCPU(), map[string]int{"critical": 6, "low": 1}},
+		{-1, map[string]int{"critical": 0, "low": -1}, runtime.NumCPU(), map[string]int{"default": 1}},
+		{1, map[string]int{}, 1, map[string]int{"default": 1}},
+	}
+
+	for _, tc := range tt {
+		s.config.WorkerConcurrency = tc.concurrency
+		s.config.WorkerQueues = tc.queues
+
+		s.Equal(tc.expectedConcurrency, s.queue.concurrency())
+		s.Equal(tc.expectedQueues, s.queue.queues())
+	}
+}
+
+func (s *kvQueueSuite) TestDequeueStrictPriority() {
+	s.config.WorkerQueues = map[string]int{"critical": 6, "default": 3}
+	s.config.WorkerStrictPriority = true
+
+	s.enqueue("default", "d1", nil)
+	s.enqueue("low", "l1", nil)
+	s.enqueue("critical", "c1", nil)
+	s.enqueue("default", "d2", nil)
+	s.enqueue("critical", "c2", nil)
+
+	names := []string{}
+	for i := 0; i < 3; i++ {
+		jobs, err := s.queue.dequeue(s.store, 2)
+		s.Nil(err)
+
+		for _, j := range jobs {
+			names = append(names, string(j.Payload))
+		}
+	}
+
+	s.Equal([]string{`{"name":"c1"}`, `{"name":"c2"}`, `{"name":"d1"}`, `{"name":"d2"}`}, names)
+	s.Equal(4, len(s.jobs(kvQueueActiveBucket)))
+
+	// The job in the queue that isn't in WORKER_QUEUES stays scheduled.
+	scheduled := s.jobs(kvQueueScheduledBucket)
+	s.Equal(1, len(scheduled))
+	for _, j := range scheduled {
+		s.Equal("low", j.Queue)
+	}
+}
+
+func (s *kvQueueSuite) TestDequeueWeightedPriority() {
+	s.config.WorkerQueues = map[string]int{"critical": 6, "default": 3}
+	s.config.WorkerStrictPriority = false
+
+	for i := 0; i < 10; i++ {
+		s.enqueue("critical", "c", nil)
+		s.enqueue("default", "d", nil)
+		s.enqueue("low", "l", nil)
+	}
+
+	jobs, err := s.queue.dequeue(s.store, 30)
+	s.Nil(err)
+	s.Equal(20, len(jobs))
+
+	queues := map[string]int{}
+	for _, j := range jobs {
+		queues[j.Queue]++
+	}
+	s.Equal(map[string]int{"critical": 10, "default": 10}, queues)
+
+	jobs, err = s.queue.dequeue(s.store, 30)
+	s.Nil(err)
+	s.Equal(0, len(jobs))
+	s.Equal(10, len(s.jobs(kvQueueScheduledBucket)))
+}
+
+func (s *kvQueueSuite) dequeueOne() *kvJob {
+	jobs, err := s.queue.dequeue(s.store, 1)
+	s.Nil(err)
+	s.Equal(1, len(jobs))
+
+	return jobs[0]
+}
+
+func (s *kvQueueSuite) TestDequeueOrder() {
+	now := time.Now()
+	s.enqueue("default", "later", &JobOptions{ProcessIn: time.Hour})
+	s.enqueue("default", "third", nil)
+	s.enqueue("default", "second", &JobOptions{ProcessAt: now.Add(-1 * time.Second)})
+	s.enqueue("default", "first", &JobOptions{ProcessAt: now.Add(-2 * time.Second)})
+
+	jobs, err := s.queue.dequeue(s.store, 10)
+	s.Nil(err)
+
+	names := []string{}
+	for _, j := range jobs {
+		names = append(names, string(j.Payload))
+	}
+	s.Equal([]string{`{"name":"first"}`, `{"name":"second"}`, `{"name":"third"}`}, names)
+
+	scheduled := s.jobs(kvQueueScheduledBucket)
+	s.Equal(1, len(scheduled))
+	for _, j := range scheduled {
+		s.Equal(`{"name":"later"}`, string(j.Payload))
+		s.WithinDuration(now.Add(time.Hour), j.ProcessAt, time.Second)
+	}
+}
+
+func (s *kvQueueSuite) TestProcessSucceeded() {
+	s.enqueue("default", "foo", nil)
+	j := s.dequeueOne()
+	s.Equal(1, len(s.jobs(kvQueueActiveBucket)))
+
+	s.queue.process(context.Background(), s.store, HandlerFunc(func(ctx context.Context, job *Job) error {
+		name, err := job.Payload.GetString("name")
+		s.Nil(err)
+		s.Equal("foo", name)
+
+		return nil
+	}), j)
+
+	s.Equal(0, len(s.jobs(kvQueueActiveBucket)))
+	s.Equal(0, len(s.jobs(kvQueueScheduledBucket)))
+	s.Equal(0, len(s.jobs(kvQueueDeadBucket)))
+}
+
+func (s *kvQueueSuite) TestProcessRetried() {
+	tt := []struct {
+		handler     HandlerFunc
+		expectedErr string
+	}{
+		{
+			func(ctx context.Context, job *Job) error { return errors.New("boom") },
+			"boom",
+		},
+		{
+			func(ctx context.Context, job *Job) error { panic("boom") },
+			"panic: boom",
+		},
+	}
+
+	for _, tc := range tt {
+		s.enqueue("default", "foo", nil)
+		j := s.dequeueOne()
+		now := time.Now()
+		s.queue.process(context.Background(), s.store, tc.handler, j)
+
+		s.Equal(0, len(s.jobs(kvQueueActiveBucket)))
+		s.Equal(0, len(s.jobs(kvQueueDeadBucket)))
+
+		scheduled := s.jobs(kvQueueScheduledBucket)
+		s.Equal(1, len(scheduled))
+		for key, j := range scheduled {
+			s.Equal(1, j.Retried)
+			s.Equal(tc.expectedErr, j.ErrorMsg)
+			s.Equal(16*time.Second, kvQueueRetryDelay(j.Retried))
+			s.WithinDuration(now.Add(kvQueueRetryDelay(j.Retried)), j.ProcessAt, time.Second)
+			s.Equal(j.scheduledKey(), key)
+
+			s.Nil(s.store.DB().Update(func(tx *bbolt.Tx) error {
+				return deleteKVJob(tx, kvQueueScheduledBucket, key)
+			}))
+		}
+	}
+}
+
+func (s *kvQueueSuite) TestProcessDead() {
+	s.enqueue("default", "foo", &JobOptions{MaxRetry: 2})
+	handler := HandlerFunc(func(ctx context.Context, job *Job) error {
+		return errors.New("boom")
+	})
+
+	for i := 1; i <= 3; i++ {
+		// Make the retried job due immediately.
+		s.Nil(s.store.DB().Update(func(tx *bbolt.Tx) error {
+			for key, j := range s.jobsInTx(tx, kvQueueScheduledBucket) {
+				if err := deleteKVJob(tx, kvQueueScheduledBucket, key); err != nil {
+					return err
+				}
+
+				j.ProcessAt = time.Now().UTC().Add(-1 * time.Second)
+				if err := putKVJob(tx, kvQueueScheduledBucket, j.scheduledKey(), j); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}))
+
+		s.queue.process(context.Background(), s.store, handler, s.dequeueOne())
+		s.Equal(0, len(s.jobs(kvQueueActiveBucket)))
+
+		if i <= 2 {
+			s.Equal(1, len(s.jobs(kvQueueScheduledBucket)))
+			s.Equal(0, len(s.jobs(kvQueueDeadBucket)))
+		}
+	}
+
+	s.Equal(0, len(s.jobs(kvQueueScheduledBucket)))
+
+	dead := s.jobs(kvQueueDeadBucket)
+	s.Equal(1, len(dead))
+	for _, j := range dead {
+		s.Equal(3, j.Retried)
+		s.Equal("boom", j.ErrorMsg)
+	}
+}
+
+func (s *kvQueueSuite) TestProcessTimeout() {
+	tt := []struct {
+		opts *JobOptions
+	}{
+		{&JobOptions{Timeout: 10 * time.Millisecond}},
+		{&JobOptions{Deadline: time.Now().Add(10 * time.Millisecond)}},
+	}
+
+	for _, tc := range tt {
+		s.enqueue("default", "foo", tc.opts)
+		j := s.dequeueOne()
+		s.queue.process(context.Background(), s.store, HandlerFunc(func(ctx context.Context, job *Job) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		}), j)
+
+		scheduled := s.jobs(kvQueueScheduledBucket)
+		s.Equal(1, len(scheduled))
+		for key, j := range scheduled {
+			s.Equal(context.DeadlineExceeded.Error(), j.ErrorMsg)
+
+			s.Nil(s.store.DB().Update(func(tx *bbolt.Tx) error {
+				return deleteKVJob(tx, kvQueueScheduledBucket, key)
+			}))
+		}
+	}
+}
+
+func (s *kvQueueSuite) TestProcessInterrupted() {
+	s.enqueue("default", "foo", nil)
+	j := s.dequeueOne()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.queue.process(ctx, s.store, HandlerFunc(func(ctx context.Context, job *Job) error {
+		cancel()
+		return ctx.Err()
+	}), j)
+
+	s.Equal(1, len(s.jobs(kvQueueActiveBucket)))
+	s.Equal(0, len(s.jobs(kvQueueScheduledBucket)))
+}
+
+func (s *kvQueueSuite) TestStartReschedulesActiveJobs() {
+	s.enqueue("default", "foo", nil)
+	s.dequeueOne()
+	s.Equal(1, len(s.jobs(kvQueueActiveBucket)))
+
+	processed := make(chan string, 1)
+	s.Nil(s.queue.start(HandlerFunc(func(ctx context.Context, job *Job) error {
+		name, err := job.Payload.GetString("name")
+		s.Nil(err)
+
+		processed <- name
+		return nil
+	})))
+
+	select {
+	case name := <-processed:
+		s.Equal("foo", name)
+	case <-time.After(5 * time.Second):
+		s.Fail("the active job isn't re-scheduled")
+	}
+
+	s.queue.stop()
+	s.Equal(0, len(s.jobs(kvQueueActiveBucket)))
+	s.Equal(0, len(s.jobs(kvQueueScheduledBucket)))
+}
+
+func TestKVQueueSuite(t *testing.T) {
+	test.Run(t, new(kvQueueSuite))
+}